@@ -1,7 +1,6 @@
 package arg
 
 import (
-	"fmt"
 	"time"
 
 	"github.com/shabbyrobe/cmdy/usage"
@@ -72,15 +71,12 @@ func (a *ArgSet) Parse(input []string) error {
 				left = input[idx:]
 			}
 			leftLen := len(left)
-			if leftLen < a.remaining.Min {
-				return fmt.Errorf("expected at least %d remaining args at position %d, found %d", a.remaining.Min, idx+1, leftLen)
+			if leftLen < a.remaining.Min || (a.remaining.Max >= 0 && leftLen > a.remaining.Max) {
+				return &RemainingCountError{Arg: arg, Range: a.remaining.Range, Position: idx + 1, Found: leftLen, Args: left}
 			}
-			if a.remaining.Max >= 0 && leftLen > a.remaining.Max {
-				return fmt.Errorf("expected at most %d remaining args at position %d, found %d", a.remaining.Max, idx+1, leftLen)
-			}
-			for _, rem := range left {
+			for remIdx, rem := range left {
 				if err := a.remaining.Set(rem); err != nil {
-					return err // FIXME
+					return &InvalidArgError{Arg: arg, Position: idx + remIdx + 1, Input: rem, Err: err}
 				}
 				consumed++
 			}
@@ -89,11 +85,11 @@ func (a *ArgSet) Parse(input []string) error {
 		} else {
 			if idx >= inputLen {
 				if !arg.optional {
-					return fmt.Errorf("missing arg %s at position %d", arg.Describe("", ""), idx+1)
+					return &MissingArgError{Arg: arg, Position: idx + 1}
 				}
 			} else {
 				if err := arg.value.Set(input[idx]); err != nil {
-					return fmt.Errorf("arg invalid at position %d: %v", idx+1, err)
+					return &InvalidArgError{Arg: arg, Position: idx + 1, Input: input[idx], Err: err}
 				}
 			}
 		}
@@ -101,11 +97,7 @@ func (a *ArgSet) Parse(input []string) error {
 	}
 
 	if consumed < inputLen {
-		extra, s := inputLen-consumed, ""
-		if extra != 1 {
-			s = "s"
-		}
-		return fmt.Errorf("found %d additional arg%s", extra, s)
+		return &ExtraArgsError{Position: consumed + 1, Count: inputLen - consumed, Args: input[consumed:]}
 	}
 
 	return nil
@@ -25,4 +25,15 @@
 //	err = arg.Parse(myFlagSet.Parse())
 //	err = arg.Parse(os.Args[1:]) // if there are no flags
 //
+// Errors
+//
+// Errors returned by ArgSet.Parse are one of MissingArgError, InvalidArgError,
+// ExtraArgsError or RemainingCountError, which you can inspect using errors.As:
+//	var invalid *arg.InvalidArgError
+//	if errors.As(err, &invalid) {
+//		fmt.Println(invalid.Arg.Name(), invalid.Position, invalid.Input)
+//	}
+//
+// Positions are 1-based and are relative to the input passed to Parse.
+//
 package arg
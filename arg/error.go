@@ -0,0 +1,87 @@
+package arg
+
+import "fmt"
+
+// MissingArgError is returned by ArgSet.Parse when a required arg is not
+// present in the input.
+type MissingArgError struct {
+	Arg *Arg
+
+	// 1-based position of the missing arg.
+	Position int
+}
+
+func (e *MissingArgError) Error() string {
+	return fmt.Sprintf("missing arg %s at position %d", e.Arg.Describe("", ""), e.Position)
+}
+
+// InvalidArgError is returned by ArgSet.Parse when the arg's ArgVal rejects
+// the input. If the arg is a Remaining arg, Position refers to the individual
+// value that was rejected, not the start of the Remaining arg.
+type InvalidArgError struct {
+	Arg *Arg
+
+	// 1-based position of the invalid arg.
+	Position int
+
+	// Input is the raw value that was passed to the ArgVal's Set method.
+	Input string
+
+	// Err is the error returned by the ArgVal's Set method.
+	Err error
+}
+
+func (e *InvalidArgError) Unwrap() error { return e.Err }
+
+func (e *InvalidArgError) Error() string {
+	return fmt.Sprintf("arg invalid at position %d: %v", e.Position, e.Err)
+}
+
+// ExtraArgsError is returned by ArgSet.Parse when there are more args in the
+// input than the ArgSet accepts.
+type ExtraArgsError struct {
+	// 1-based position of the first unexpected arg.
+	Position int
+
+	// Number of unexpected args.
+	Count int
+
+	// The unexpected args themselves.
+	Args []string
+}
+
+func (e *ExtraArgsError) Error() string {
+	s := ""
+	if e.Count != 1 {
+		s = "s"
+	}
+	return fmt.Sprintf("found %d additional arg%s", e.Count, s)
+}
+
+// RemainingCountError is returned by ArgSet.Parse when the number of args
+// collected by a Remaining arg is outside the Range it was defined with.
+type RemainingCountError struct {
+	Arg   *Arg
+	Range Range
+
+	// 1-based position of the Remaining arg.
+	Position int
+
+	// Number of remaining args that were found.
+	Found int
+
+	// The remaining args themselves.
+	Args []string
+}
+
+// TooFew returns true if fewer args were found than the Range's Min.
+func (e *RemainingCountError) TooFew() bool {
+	return e.Found < e.Range.Min
+}
+
+func (e *RemainingCountError) Error() string {
+	if e.TooFew() {
+		return fmt.Sprintf("expected at least %d remaining args at position %d, found %d", e.Range.Min, e.Position, e.Found)
+	}
+	return fmt.Sprintf("expected at most %d remaining args at position %d, found %d", e.Range.Max, e.Position, e.Found)
+}
@@ -0,0 +1,99 @@
+package arg
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/shabbyrobe/cmdy/internal/assert"
+)
+
+func TestParseErrorMissing(t *testing.T) {
+	tt := assert.WrapTB(t)
+	var foo, bar string
+	as := NewArgSet()
+	as.String(&foo, "foo", "Usage...")
+	as.String(&bar, "bar", "Usage...")
+
+	err := as.Parse([]string{"a"})
+	var missing *MissingArgError
+	tt.MustAssert(errors.As(fmt.Errorf("wrapped: %w", err), &missing))
+	tt.MustEqual("bar", missing.Arg.Name())
+	tt.MustEqual(2, missing.Position)
+}
+
+func TestParseErrorInvalid(t *testing.T) {
+	tt := assert.WrapTB(t)
+	var foo string
+	var bar int
+	as := NewArgSet()
+	as.String(&foo, "foo", "Usage...")
+	as.Int(&bar, "bar", "Usage...")
+
+	err := as.Parse([]string{"a", "nope"})
+	var invalid *InvalidArgError
+	tt.MustAssert(errors.As(err, &invalid))
+	tt.MustEqual("bar", invalid.Arg.Name())
+	tt.MustEqual(2, invalid.Position)
+	tt.MustEqual("nope", invalid.Input)
+
+	var numErr *strconv.NumError
+	tt.MustAssert(errors.As(err, &numErr))
+	tt.MustEqual(strconv.ErrSyntax, numErr.Err)
+}
+
+func TestParseErrorInvalidRemaining(t *testing.T) {
+	tt := assert.WrapTB(t)
+	var foo string
+	var rem []int
+	as := NewArgSet()
+	as.String(&foo, "foo", "Usage...")
+	as.RemainingInts(&rem, "rem", AnyLen, "Usage...")
+
+	err := as.Parse([]string{"a", "1", "2", "nope", "4"})
+	var invalid *InvalidArgError
+	tt.MustAssert(errors.As(err, &invalid))
+	tt.MustEqual("rem", invalid.Arg.Name())
+	tt.MustEqual(4, invalid.Position)
+	tt.MustEqual("nope", invalid.Input)
+	tt.MustEqual("arg invalid at position 4: "+invalid.Err.Error(), err.Error())
+}
+
+func TestParseErrorExtra(t *testing.T) {
+	tt := assert.WrapTB(t)
+	var foo string
+	as := NewArgSet()
+	as.String(&foo, "foo", "Usage...")
+
+	err := as.Parse([]string{"a", "b", "c"})
+	var extra *ExtraArgsError
+	tt.MustAssert(errors.As(err, &extra))
+	tt.MustEqual(2, extra.Position)
+	tt.MustEqual(2, extra.Count)
+	tt.MustEqual([]string{"b", "c"}, extra.Args)
+}
+
+func TestParseErrorRemainingCount(t *testing.T) {
+	tt := assert.WrapTB(t)
+	var foo string
+	var rem []string
+	as := NewArgSet()
+	as.String(&foo, "foo", "Usage...")
+	as.Remaining(&rem, "rem", MinMax(2, 3), "Usage...")
+
+	err := as.Parse([]string{"a", "b"})
+	var count *RemainingCountError
+	tt.MustAssert(errors.As(err, &count))
+	tt.MustAssert(count.TooFew())
+	tt.MustEqual("rem", count.Arg.Name())
+	tt.MustEqual(2, count.Position)
+	tt.MustEqual(1, count.Found)
+	tt.MustEqual([]string{"b"}, count.Args)
+
+	err = as.Parse([]string{"a", "b", "c", "d", "e"})
+	tt.MustAssert(errors.As(err, &count))
+	tt.MustAssert(!count.TooFew())
+	tt.MustEqual(4, count.Found)
+	tt.MustEqual("expected at most 3 remaining args at position 2, found 4", err.Error())
+}
@@ -0,0 +1,77 @@
+// +build go1.18
+
+package arg
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func FuzzArgSetParse(f *testing.F) {
+	for _, seed := range []string{
+		"", "foo", "foo\x001", "foo\x001\x001.5\x00true\x001s\x00a\x00b",
+		"foo\x00x", "foo\x001\x00-1\x00nope\x00maybe\x00later",
+		"\x00\x00\x00\x00\x00\x00\x00\x00\x00",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, in string) {
+		var input []string
+		if in != "" {
+			input = strings.Split(in, "\x00")
+		}
+
+		var (
+			s    string
+			i    int
+			u    uint64
+			fl   float64
+			b    bool
+			d    time.Duration
+			rest []string
+		)
+		as := NewArgSet()
+		as.String(&s, "str", "")
+		as.IntOptional(&i, "int", 0, "")
+		as.Uint64Optional(&u, "uint", 0, "")
+		as.Float64Optional(&fl, "float", 0, "")
+		as.BoolOptional(&b, "bool", false, "")
+		as.DurationOptional(&d, "dur", 0, "")
+		as.Remaining(&rest, "rest", Range{Min: 0, Max: 2}, "")
+
+		err := as.Parse(input)
+		if err == nil {
+			return
+		}
+
+		var (
+			missing   *MissingArgError
+			invalid   *InvalidArgError
+			extra     *ExtraArgsError
+			remaining *RemainingCountError
+		)
+		switch {
+		case errors.As(err, &missing):
+			if missing.Position != len(input)+1 {
+				t.Fatalf("missing arg at position %d for %d inputs", missing.Position, len(input))
+			}
+		case errors.As(err, &invalid):
+			if invalid.Position < 1 || invalid.Position > len(input) || input[invalid.Position-1] != invalid.Input {
+				t.Fatalf("invalid arg position %d does not match input %q", invalid.Position, invalid.Input)
+			}
+		case errors.As(err, &extra):
+			if extra.Position < 1 || extra.Position+extra.Count-1 != len(input) {
+				t.Fatalf("extra args position %d and count %d do not match %d inputs", extra.Position, extra.Count, len(input))
+			}
+		case errors.As(err, &remaining):
+			if remaining.Found != len(remaining.Args) {
+				t.Fatalf("remaining found %d, but %d args", remaining.Found, len(remaining.Args))
+			}
+		default:
+			t.Fatalf("unexpected error type %T: %v", err, err)
+		}
+	})
+}
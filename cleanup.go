@@ -0,0 +1,174 @@
+package cmdy
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultCleanupTimeout is how long each cleanup function registered with
+// OnCleanup has to complete.
+const DefaultCleanupTimeout = 5 * time.Second
+
+// CleanupFunc is a function registered with OnCleanup. ctx is not derived from
+// the command's Context, which is likely to have been cancelled by the time
+// cleanup functions run; its deadline is the function's timeout.
+type CleanupFunc func(ctx context.Context) error
+
+// Cleanups is a list of cleanup functions, run in the reverse of the order
+// they were added. Runner.Run creates a Cleanups for each command it runs,
+// unless one has been passed to it using WithCleanups.
+//
+// The zero value is ready to use.
+type Cleanups struct {
+	mu    sync.Mutex
+	funcs []cleanupEntry
+	done  bool
+}
+
+type cleanupEntry struct {
+	fn      CleanupFunc
+	timeout time.Duration
+}
+
+// Add adds a cleanup function with the DefaultCleanupTimeout.
+func (c *Cleanups) Add(fn CleanupFunc) {
+	c.AddTimeout(DefaultCleanupTimeout, fn)
+}
+
+// AddTimeout adds a cleanup function that is given timeout to complete. If
+// Run has already been called, fn is run immediately.
+func (c *Cleanups) AddTimeout(timeout time.Duration, fn CleanupFunc) {
+	c.mu.Lock()
+	if c.done {
+		c.mu.Unlock()
+		runCleanup(cleanupEntry{fn, timeout})
+		return
+	}
+	c.funcs = append(c.funcs, cleanupEntry{fn, timeout})
+	c.mu.Unlock()
+}
+
+// Run calls each cleanup function in the reverse of the order they were
+// added. If a function does not return before its timeout expires, Run stops
+// waiting for it and moves on to the next one.
+//
+// Run only runs each function once, so it is safe to call more than once; any
+// functions added after the first call are run as soon as they are added.
+//
+// If any of the functions fail, Run returns an error that implements
+// 'Errors() []error' and 'Unwrap() []error', which FormatError prints as a
+// list.
+func (c *Cleanups) Run() error {
+	c.mu.Lock()
+	funcs := c.funcs
+	c.funcs, c.done = nil, true
+	c.mu.Unlock()
+
+	var errs cleanupErrors
+	for i := len(funcs) - 1; i >= 0; i-- {
+		if err := runCleanup(funcs[i]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func runCleanup(entry cleanupEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), entry.timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- entry.fn(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("cmdy: cleanup did not complete within %s", entry.timeout)
+	}
+}
+
+type cleanupErrors []error
+
+func (c cleanupErrors) Errors() []error { return c }
+func (c cleanupErrors) Unwrap() []error { return c }
+
+func (c cleanupErrors) Error() string {
+	if len(c) == 1 {
+		return "cleanup failed: " + c[0].Error()
+	}
+	return fmt.Sprintf("%d cleanups failed, first error: %v", len(c), c[0])
+}
+
+type cleanupsKey struct{}
+
+// cleanupsValue is stored in a Context under cleanupsKey. supplied is true if
+// it was added by WithCleanups, rather than by Runner.Run.
+type cleanupsValue struct {
+	cleanups *Cleanups
+	supplied bool
+}
+
+// WithCleanups returns a copy of ctx that carries c. If ctx is passed directly
+// to Runner.Run, functions registered with OnCleanup are added to c instead of
+// to a Cleanups owned by the Runner. Runner.Run still runs c before its
+// services are closed, but the caller should also call c.Run in case
+// Runner.Run never returns.
+//
+// This allows wrappers like cmdyutil.InterruptRunner to run cleanups even if
+// they give up waiting for the command to finish.
+//
+// A Context derived from a command's Context (for example, by a command that
+// starts a nested Runner.Run, like cmdyutil.Shell) does not pass the
+// command's Cleanups on to the nested Run; it gets its own Cleanups unless
+// WithCleanups is called again.
+func WithCleanups(ctx context.Context, c *Cleanups) context.Context {
+	return context.WithValue(ctx, cleanupsKey{}, cleanupsValue{cleanups: c, supplied: true})
+}
+
+// suppliedCleanups returns the Cleanups passed to WithCleanups, if the
+// innermost Cleanups in ctx was not added by Runner.Run.
+func suppliedCleanups(ctx context.Context) *Cleanups {
+	v, _ := ctx.Value(cleanupsKey{}).(cleanupsValue)
+	if !v.supplied {
+		return nil
+	}
+	return v.cleanups
+}
+
+// CleanupsFromContext returns the Cleanups that OnCleanup would add to, or
+// nil if there aren't any.
+func CleanupsFromContext(ctx context.Context) *Cleanups {
+	v, _ := ctx.Value(cleanupsKey{}).(cleanupsValue)
+	return v.cleanups
+}
+
+// OnCleanup registers a function that is called after the outermost
+// Command.Run returns, with the DefaultCleanupTimeout. Cleanup functions are
+// called in the reverse of the order they were registered, even if Run
+// returns an error. Use them to remove temporary files, release locks and
+// restore terminal state.
+//
+// If any cleanup function fails and the command succeeded, Runner.Run returns
+// the cleanup errors.
+//
+// OnCleanup panics if ctx was not passed to a Command by Runner.Run.
+func OnCleanup(ctx context.Context, fn CleanupFunc) {
+	OnCleanupTimeout(ctx, DefaultCleanupTimeout, fn)
+}
+
+// OnCleanupTimeout is like OnCleanup, but fn is given timeout to complete.
+func OnCleanupTimeout(ctx context.Context, timeout time.Duration, fn CleanupFunc) {
+	c := CleanupsFromContext(ctx)
+	if c == nil {
+		panic(fmt.Errorf("cmdy: OnCleanup requires a Context created by Runner.Run"))
+	}
+	c.AddTimeout(timeout, fn)
+}
@@ -0,0 +1,124 @@
+package cmdy
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shabbyrobe/cmdy/internal/assert"
+)
+
+func TestCleanupOrder(t *testing.T) {
+	tt := assert.WrapTB(t)
+
+	var log []string
+	record := func(name string) CleanupFunc {
+		return func(ctx context.Context) error {
+			log = append(log, name)
+			return nil
+		}
+	}
+
+	bld := func() Command {
+		return NewGroup("grp", Builders{
+			"sub": testCmdRunBuilder(func(c Context) error {
+				OnCleanup(c, record("sub1"))
+				OnCleanup(c, record("sub2"))
+				log = append(log, "run")
+				return errors.New("boom")
+			}),
+		}, GroupBefore(func(c Context) error {
+			OnCleanup(c, record("grp"))
+			return nil
+		}))
+	}
+
+	rn := NewBufferedRunner()
+	err := rn.Run(context.Background(), "test", []string{"sub"}, bld)
+	tt.MustEqual("boom", err.Error())
+	tt.MustEqual([]string{"run", "sub2", "sub1", "grp"}, log)
+}
+
+func TestCleanupError(t *testing.T) {
+	tt := assert.WrapTB(t)
+
+	rn := NewBufferedRunner()
+	err := rn.Run(context.Background(), "test", nil, testCmdRunBuilder(func(c Context) error {
+		OnCleanup(c, func(ctx context.Context) error { return errors.New("a") })
+		OnCleanup(c, func(ctx context.Context) error { return errors.New("b") })
+		return nil
+	}))
+	tt.MustAssert(err != nil)
+	msg, code := FormatError(err)
+	tt.MustEqual(ExitFailure, code)
+	tt.MustEqual("- b\n- a", msg)
+}
+
+func TestCleanupTimeout(t *testing.T) {
+	tt := assert.WrapTB(t)
+
+	var ran bool
+	block := make(chan struct{})
+	defer close(block)
+
+	var c Cleanups
+	c.Add(func(ctx context.Context) error { ran = true; return nil })
+	c.AddTimeout(time.Millisecond, func(ctx context.Context) error {
+		<-block
+		return nil
+	})
+
+	err := c.Run()
+	tt.MustAssert(err != nil)
+	tt.MustAssert(strings.Contains(err.Error(), "did not complete within 1ms"), err)
+
+	// Later cleanups still run if an earlier one times out:
+	tt.MustAssert(ran)
+}
+
+func TestCleanupsRunOnce(t *testing.T) {
+	tt := assert.WrapTB(t)
+
+	var calls int
+	var c Cleanups
+	c.Add(func(ctx context.Context) error { calls++; return nil })
+	tt.MustOK(c.Run())
+	tt.MustOK(c.Run())
+	tt.MustEqual(1, calls)
+
+	// Functions added after Run are run immediately:
+	c.Add(func(ctx context.Context) error { calls++; return nil })
+	tt.MustEqual(2, calls)
+}
+
+func TestCleanupsFromContext(t *testing.T) {
+	tt := assert.WrapTB(t)
+
+	var ran bool
+	var c Cleanups
+	ctx := WithCleanups(context.Background(), &c)
+
+	rn := NewBufferedRunner()
+	tt.MustOK(rn.Run(ctx, "test", nil, testCmdRunBuilder(func(c Context) error {
+		OnCleanup(c, func(ctx context.Context) error { ran = true; return nil })
+		return nil
+	})))
+
+	// The Runner runs the Cleanups even though it does not own them, so
+	// running them again does nothing:
+	tt.MustAssert(ran)
+	ran = false
+	tt.MustOK(c.Run())
+	tt.MustAssert(!ran)
+}
+
+func TestOnCleanupPanicsOutsideRunner(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic")
+		}
+	}()
+	OnCleanup(context.Background(), func(ctx context.Context) error { return nil })
+}
@@ -1,10 +1,14 @@
+// Package cmdstr splits command strings into arguments using shell-esque rules,
+// and quotes arguments so they can be joined back into a command string.
+//
+// It is used by cmdy to parse the Command in a cmdy.Example, but is not a full
+// shell parser: there is no variable expansion, globbing, redirection or
+// command substitution.
 package cmdstr
 
 import (
 	"errors"
 	"fmt"
-	"reflect"
-	"unsafe"
 )
 
 type stateType int
@@ -72,6 +76,8 @@ const (
 //    0x5c 0x0a (\\n) --> <nothing>
 //    0x5c 0x22 (\")  --> 0x22
 //    0x5c 0x5c (\\)  --> 0x5c
+//    0x5c 0x24 (\$)  --> 0x24
+//    0x5c 0x60 (\`)  --> 0x60
 //
 // All other backslashes are copied literally into the output:
 //
@@ -82,7 +88,7 @@ func Parse(cmd []byte, endset string) (out []string, n int, rerr error) {
 		cur      string
 		sz       = len(cmd)
 		wsp      = [256]bool{'\r': true, '\n': true, '\t': true, ' ': true}
-		esc      = [256]byte{'n': '\n', '"': '"', '\\': '\\'}
+		esc      = [256]byte{'n': '\n', '"': '"', '\\': '\\', '$': '$', '`': '`'}
 		end      = [256]bool{}
 		stack    = [4]stateType{}
 		stackPos = 0
@@ -217,13 +223,7 @@ end:
 
 // ParseString implements the string-version of Parse. See Parse for more details.
 func ParseString(cmd string, endset string) ([]string, error) {
-	hdr := *(*reflect.StringHeader)(unsafe.Pointer(&cmd))
-	cmdBytes := *(*[]byte)(unsafe.Pointer(&reflect.SliceHeader{
-		Data: hdr.Data,
-		Len:  hdr.Len,
-		Cap:  hdr.Len,
-	}))
-	out, _, err := Parse(cmdBytes, endset)
+	out, _, err := Parse([]byte(cmd), endset)
 	return out, err
 }
 
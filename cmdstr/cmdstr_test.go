@@ -81,6 +81,10 @@ func TestSplitString(t *testing.T) {
 	// Unknown escape is literal:
 	yep(t, `"\s"`, `\s`)
 
+	// Shell metacharacters can be escaped:
+	yep(t, "\"\\$HOME \\`pwd\\`\"", "$HOME `pwd`")
+	yep(t, `\$HOME`, "$HOME")
+
 	// intra-unqouted quotes are passed through:
 	yep(t, `foo"bar"baz`, `foo"bar"baz`)
 	yep(t, `foo'bar'baz`, `foo'bar'baz`)
@@ -0,0 +1,66 @@
+// +build go1.18
+
+package cmdstr
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func FuzzParse(f *testing.F) {
+	for _, seed := range []string{
+		"", "foo bar", `"foo bar" 'baz qux'`, `foo"bar"baz`, `a\nb`,
+		"foo \\\nbar", `"unterminated`, `'unterminated`, `foo\`, `a "\"" b`,
+		"foo; bar", "foo|bar",
+	} {
+		f.Add(seed, "")
+		f.Add(seed, ";|")
+	}
+
+	f.Fuzz(func(t *testing.T, in string, endset string) {
+		out, n, err := Parse([]byte(in), endset)
+		if n < 0 || n > len(in) {
+			t.Fatalf("n %d out of range for input of length %d", n, len(in))
+		}
+		if err != nil {
+			if !errors.Is(err, ErrIncompleteCommand) {
+				t.Fatalf("unexpected error type: %v", err)
+			}
+			if out != nil {
+				t.Fatalf("expected nil output with error, found %q", out)
+			}
+			return
+		}
+
+		if endset == "" && n != len(in) {
+			t.Fatalf("expected to consume all %d bytes, consumed %d", len(in), n)
+		}
+
+		// Parsing should stop at the end character without consuming anything
+		// past it, so the prefix should parse to the same result:
+		prefix, err := ParseString(in[:n], "")
+		if err != nil {
+			t.Fatalf("prefix %q failed to parse: %v", in[:n], err)
+		}
+		if !reflect.DeepEqual(prefix, out) {
+			t.Fatalf("prefix %q parsed to %q, expected %q", in[:n], prefix, out)
+		}
+	})
+}
+
+func FuzzQuote(f *testing.F) {
+	for _, seed := range []string{"", "foo", "foo bar", "it's", `a\nb`, "$`\"\\'\n\t"} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, in string) {
+		out, err := ParseString(Quote(in), "")
+		if err != nil {
+			t.Fatalf("Quote(%q) = %q failed to parse: %v", in, Quote(in), err)
+		}
+		if len(out) != 1 || out[0] != in {
+			t.Fatalf("Quote(%q) = %q parsed to %q", in, Quote(in), out)
+		}
+	})
+}
@@ -0,0 +1,80 @@
+package cmdstr
+
+import "strings"
+
+// Quote returns s quoted so that Parse returns it as a single argument. The
+// result is also safe to paste into a POSIX shell, which will see the same
+// argument.
+//
+// Strings that only contain characters which have no special meaning are
+// returned as-is. Otherwise, single quotes are preferred, unless s contains a
+// single quote or a backslash, in which case s is double quoted and '"', '\',
+// '$' and '`' are escaped with a backslash.
+func Quote(s string) string {
+	if s == "" {
+		return "''"
+	}
+
+	bare, single := true, true
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !safe[c] {
+			bare = false
+		}
+		if c == '\'' || c == '\\' {
+			single = false
+		}
+	}
+
+	switch {
+	case bare:
+		return s
+	case single:
+		return "'" + s + "'"
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(s) + 2)
+	sb.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case '"', '\\', '$', '`':
+			sb.WriteByte('\\')
+		}
+		sb.WriteByte(c)
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+// Join quotes each argument using Quote and joins them with spaces. The result
+// can be split back into args using Parse.
+func Join(args []string) string {
+	var sb strings.Builder
+	for i, arg := range args {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(Quote(arg))
+	}
+	return sb.String()
+}
+
+// safe contains the characters which never need to be quoted, either by Parse
+// or by a POSIX shell.
+var safe = func() (safe [256]bool) {
+	for c := 'a'; c <= 'z'; c++ {
+		safe[c] = true
+	}
+	for c := 'A'; c <= 'Z'; c++ {
+		safe[c] = true
+	}
+	for c := '0'; c <= '9'; c++ {
+		safe[c] = true
+	}
+	for _, c := range "@%+=:,./-_" {
+		safe[c] = true
+	}
+	return safe
+}()
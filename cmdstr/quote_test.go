@@ -0,0 +1,51 @@
+package cmdstr
+
+import (
+	"testing"
+
+	"github.com/shabbyrobe/cmdy/internal/assert"
+)
+
+func TestQuote(t *testing.T) {
+	for idx, tc := range []struct {
+		in, out string
+	}{
+		{"", "''"},
+		{"foo", "foo"},
+		{"-flag=a/b.c", "-flag=a/b.c"},
+		{"foo bar", "'foo bar'"},
+		{"$HOME", "'$HOME'"},
+		{`"yep"`, `'"yep"'`},
+		{"a\nb", "'a\nb'"},
+		{"日本語", "'日本語'"},
+		{"it's", `"it's"`},
+		{`a\nb`, `"a\\nb"`},
+		{"it's $HOME `pwd` \"x\"", `"it's \$HOME \` + "`pwd\\`" + ` \"x\""`},
+	} {
+		result := Quote(tc.in)
+		if result != tc.out {
+			t.Fatalf("%d: Quote(%q): expected %q, found %q", idx, tc.in, tc.out, result)
+		}
+	}
+}
+
+func TestJoinRoundTrip(t *testing.T) {
+	tt := assert.WrapTB(t)
+	for _, args := range [][]string{
+		{},
+		{""},
+		{"", ""},
+		{"foo", "bar baz"},
+		{"it's", `back\slash`, `"quoted"`, "$VAR", "`cmd`"},
+		{"new\nline", "tab\there", "\\\n", `\n`, `\`, "'", `"`},
+		{"-flag=some value", "--", "-"},
+	} {
+		out, err := ParseString(Join(args), "")
+		tt.MustOK(err)
+		if len(args) == 0 {
+			tt.MustEqual(0, len(out))
+		} else {
+			tt.MustEqual(args, out)
+		}
+	}
+}
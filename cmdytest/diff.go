@@ -0,0 +1,67 @@
+package cmdytest
+
+import (
+	"strings"
+)
+
+// lineDiff produces a simple line-oriented diff between expected and actual,
+// suitable for test failure messages. Lines prefixed with '-' are expected
+// but missing, lines prefixed with '+' are present but unexpected.
+//
+// It uses the longest common subsequence of lines, which is quadratic in the
+// number of lines; this is fine for the size of output produced by commands
+// under test.
+func lineDiff(expected, actual string) string {
+	exp := strings.Split(expected, "\n")
+	act := strings.Split(actual, "\n")
+
+	// lcs[i][j] is the length of the longest common subsequence of exp[i:]
+	// and act[j:]:
+	lcs := make([][]int, len(exp)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(act)+1)
+	}
+	for i := len(exp) - 1; i >= 0; i-- {
+		for j := len(act) - 1; j >= 0; j-- {
+			if exp[i] == act[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var out strings.Builder
+	out.WriteString("--- expected\n+++ actual\n")
+
+	line := func(prefix, s string) {
+		out.WriteString(prefix)
+		out.WriteString(s)
+		out.WriteByte('\n')
+	}
+
+	i, j := 0, 0
+	for i < len(exp) && j < len(act) {
+		if exp[i] == act[j] {
+			line("  ", exp[i])
+			i++
+			j++
+		} else if lcs[i+1][j] >= lcs[i][j+1] {
+			line("- ", exp[i])
+			i++
+		} else {
+			line("+ ", act[j])
+			j++
+		}
+	}
+	for ; i < len(exp); i++ {
+		line("- ", exp[i])
+	}
+	for ; j < len(act); j++ {
+		line("+ ", act[j])
+	}
+
+	return strings.TrimRight(out.String(), "\n")
+}
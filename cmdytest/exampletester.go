@@ -3,23 +3,47 @@ package cmdytest
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 	"testing"
 
 	"github.com/shabbyrobe/cmdy"
-	"github.com/shabbyrobe/cmdy/internal/cmdstr"
+	"github.com/shabbyrobe/cmdy/cmdstr"
 )
 
 // ExampleTester tests a set of examples from a cmdy.Help.
 //
 // NOTE: this is an experimental API and may change without warning.
 //
+// Examples run using cmdy.ExampleRun have their Output and Stderr compared
+// against what the command wrote, according to the example's OutputMode. The
+// default OutputMode, cmdy.ExampleOutputIgnore, does not check them.
+//
+// If GoldenDir is set, the expected output for examples with an empty Output
+// or Stderr and an OutputMode other than ExampleOutputIgnore can be kept in
+// files in that directory instead, named after the TestName and the example's
+// Desc. Pass '-cmdytest.update' to 'go test' (see RegisterUpdateFlag), or set
+// Update, to write the command's actual output to those files. An example
+// fails if its golden file is missing, unless it is the stderr file and the
+// command wrote nothing to stderr.
+//
+// Examples that use Env, Files, Dir or OutputFiles are run in a temporary
+// working directory, which is available to the command in the $WORK
+// environment variable. As the working directory and environment are
+// process-wide, these examples must not be run in parallel.
+//
 type ExampleTester struct {
 	TestName string
 	Builder  cmdy.Builder
 	Setup    func(cmd cmdy.Command)
 	Cleanup  func(cmd cmdy.Command)
+
+	GoldenDir string
+	Update    bool
 }
 
 func (e *ExampleTester) wrapBuilder(example cmdy.Example) cmdy.Builder {
@@ -52,12 +76,25 @@ func (e *ExampleTester) TestExample(t *testing.T, example cmdy.Example) {
 	})
 }
 
-func (e *ExampleTester) RunExample(example cmdy.Example) error {
+func (e *ExampleTester) RunExample(example cmdy.Example) (rerr error) {
 	args, err := cmdstr.ParseString(example.Command, "")
 	if err != nil {
 		return err
 	}
 
+	var sb *sandbox
+	if example.NeedsSandbox() {
+		sb, err = setupExampleSandbox(example)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := sb.close(); err != nil && rerr == nil {
+				rerr = err
+			}
+		}()
+	}
+
 	ctx := context.Background()
 	runner := cmdy.NewBufferedRunner()
 	runner.StdinBuffer.WriteString(example.Input)
@@ -73,11 +110,142 @@ func (e *ExampleTester) RunExample(example cmdy.Example) error {
 		return fmt.Errorf("unexpected success, expected non-zero exit code: %w", runErr)
 	}
 
-	// FIXME: test output
+	if example.TestMode == cmdy.ExampleRun {
+		stdout, stderr := runner.StdoutBuffer.String(), runner.StderrBuffer.String()
+		if sb != nil {
+			// Replace the sandbox path so output is stable between runs:
+			stdout = strings.Replace(stdout, sb.dir, "$WORK", -1)
+			stderr = strings.Replace(stderr, sb.dir, "$WORK", -1)
+		}
+		if err := e.checkOutput(example, "stdout", example.Output, stdout); err != nil {
+			return err
+		}
+		if err := e.checkOutput(example, "stderr", example.Stderr, stderr); err != nil {
+			return err
+		}
+		if err := checkOutputFiles(sb, example); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *ExampleTester) checkOutput(example cmdy.Example, stream, expected, actual string) error {
+	if expected != "" {
+		return matchOutput(stream, example.OutputMode, expected, actual)
+	}
+	if e.GoldenDir == "" || example.OutputMode == cmdy.ExampleOutputIgnore {
+		return nil
+	}
+
+	golden := goldenFile{
+		path:   filepath.Join(e.GoldenDir, slugify(e.TestName+" "+example.Desc)+"."+stream),
+		update: e.Update || UpdateGolden(),
+	}
+
+	// Empty stderr is the norm; don't litter the golden dir with empty files.
+	// A missing stderr file means no output is expected:
+	if stream == "stderr" && actual == "" {
+		if golden.update {
+			if err := os.Remove(golden.path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			return nil
+		}
+		if _, err := os.Stat(golden.path); os.IsNotExist(err) {
+			return nil
+		}
+	}
+
+	return golden.check(stream, example.OutputMode, actual)
+}
+
+// setupExampleSandbox creates the temporary working directory for an example
+// that uses Env, Files, Dir or OutputFiles. Env values may refer to the
+// directory using $WORK; see expandWork.
+func setupExampleSandbox(example cmdy.Example) (sb *sandbox, rerr error) {
+	sb, err := newSandbox()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if rerr != nil {
+			sb.close()
+		}
+	}()
+
+	for _, name := range sortedKeys(example.Files) {
+		if err := sb.writeFile(name, []byte(example.Files[name])); err != nil {
+			return nil, err
+		}
+	}
+	if example.Dir != "" {
+		if err := sb.chdir(example.Dir); err != nil {
+			return nil, err
+		}
+	}
+	for _, kv := range example.Env {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid env %q", kv)
+		}
+		if err := sb.setenv(parts[0], expandWork(parts[1], sb.dir)); err != nil {
+			return nil, err
+		}
+	}
+	return sb, nil
+}
 
+// expandWork replaces $WORK or ${WORK} in s with dir. Other variables are
+// left alone, so Env values don't depend on the environment of the test
+// process, and '$$' is replaced with a literal '$'.
+func expandWork(s, dir string) string {
+	return os.Expand(s, func(name string) string {
+		switch name {
+		case "WORK":
+			return dir
+		case "$":
+			return "$"
+		default:
+			return "$" + name
+		}
+	})
+}
+
+// checkOutputFiles checks the example's OutputFiles, even if its stdout is
+// not checked.
+func checkOutputFiles(sb *sandbox, example cmdy.Example) error {
+	mode := example.OutputMode
+	if mode == cmdy.ExampleOutputIgnore {
+		mode = cmdy.ExampleOutputTrimmed
+	}
+	for _, name := range sortedKeys(example.OutputFiles) {
+		full, err := sb.rootPath(name)
+		if err != nil {
+			return err
+		}
+		actual, err := ioutil.ReadFile(full)
+		if err != nil {
+			return fmt.Errorf("output file %q: %w", name, err)
+		}
+		stream := fmt.Sprintf("file %q", name)
+		if err := matchOutput(stream, mode, example.OutputFiles[name], string(actual)); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 type wrappedCommand struct {
 	cmdy.Command
 	tester  *ExampleTester
@@ -1,7 +1,12 @@
 package cmdytest
 
 import (
+	"flag"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/shabbyrobe/cmdy"
@@ -63,3 +68,233 @@ func TestExampleTester(t *testing.T) {
 		})
 	}
 }
+
+type outputCommand struct {
+	stdout, stderr string
+}
+
+func (cmd *outputCommand) Help() cmdy.Help                                 { return cmdy.Help{} }
+func (cmd *outputCommand) Configure(flags *cmdy.FlagSet, args *arg.ArgSet) {}
+
+func (cmd *outputCommand) Run(ctx cmdy.Context) error {
+	fmt.Fprint(ctx.Stdout(), cmd.stdout)
+	fmt.Fprint(ctx.Stderr(), cmd.stderr)
+	return nil
+}
+
+func TestExampleTesterOutput(t *testing.T) {
+	bld := func() cmdy.Command {
+		return &outputCommand{stdout: "hello\nworld\n", stderr: "warning!\n"}
+	}
+
+	validExamples := cmdy.Examples{
+		{Output: "hello\nworld", OutputMode: cmdy.ExampleOutputTrimmed},
+		{Output: "hello\nworld\n", OutputMode: cmdy.ExampleOutputExact},
+		{Output: `^hello\s+w.*d\n$`, OutputMode: cmdy.ExampleOutputRegexp},
+		{Output: "hello\nwo", OutputMode: cmdy.ExampleOutputPrefix},
+		{Output: "lo\nwo", OutputMode: cmdy.ExampleOutputContains},
+		{Output: "nope", OutputMode: cmdy.ExampleOutputIgnore},
+		{Output: "nope", Stderr: "nope"},
+		{Output: "hello\nworld", Stderr: "warning!", OutputMode: cmdy.ExampleOutputTrimmed},
+		{Output: "", Stderr: "warn", OutputMode: cmdy.ExampleOutputPrefix},
+	}
+
+	for _, ex := range validExamples {
+		t.Run("", func(t *testing.T) {
+			ex.TestMode = cmdy.ExampleRun
+			tester := ExampleTester{TestName: "yep", Builder: bld}
+			if err := tester.RunExample(ex); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+
+	invalidExamples := cmdy.Examples{
+		{Output: "hello\nwurld", OutputMode: cmdy.ExampleOutputTrimmed},
+		{Output: "hello\nworld", OutputMode: cmdy.ExampleOutputExact},
+		{Output: `^world`, OutputMode: cmdy.ExampleOutputRegexp},
+		{Output: `(`, OutputMode: cmdy.ExampleOutputRegexp},
+		{Output: "world", OutputMode: cmdy.ExampleOutputPrefix},
+		{Output: "hello world", OutputMode: cmdy.ExampleOutputContains},
+		{Output: "hello\nworld", Stderr: "nope", OutputMode: cmdy.ExampleOutputTrimmed},
+	}
+
+	for _, ex := range invalidExamples {
+		t.Run("", func(t *testing.T) {
+			ex.TestMode = cmdy.ExampleRun
+			tester := ExampleTester{TestName: "yep", Builder: bld}
+			if err := tester.RunExample(ex); err == nil {
+				t.Fatalf("expected error for output %q", ex.Output)
+			}
+		})
+	}
+}
+
+func TestExampleTesterOutputNotCheckedWhenParseOnly(t *testing.T) {
+	tester := ExampleTester{
+		TestName: "yep",
+		Builder:  func() cmdy.Command { return &outputCommand{stdout: "yep"} },
+	}
+	if err := tester.RunExample(cmdy.Example{Output: "nope"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExampleTesterGolden(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	out := "hello\nworld\n"
+	bld := func() cmdy.Command { return &outputCommand{stdout: out} }
+	ex := cmdy.Example{Desc: "golden", TestMode: cmdy.ExampleRun, OutputMode: cmdy.ExampleOutputTrimmed}
+
+	tester := ExampleTester{TestName: "yep", Builder: bld, GoldenDir: dir}
+
+	// No golden file yet:
+	err = tester.RunExample(ex)
+	if err == nil || !strings.Contains(err.Error(), "missing golden file") {
+		t.Fatal("expected missing golden file error, found", err)
+	}
+
+	tester.Update = true
+	if err := tester.RunExample(ex); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	if len(files) != 1 || !strings.HasSuffix(files[0], ".stdout") {
+		t.Fatal("unexpected golden files", files)
+	}
+
+	tester.Update = false
+	if err := tester.RunExample(ex); err != nil {
+		t.Fatal(err)
+	}
+
+	out = "hello\nwurld\n"
+	err = tester.RunExample(ex)
+	if err == nil || !strings.Contains(err.Error(), "- world\n+ wurld") {
+		t.Fatal("expected diff in error, found", err)
+	}
+}
+
+func TestRegisterUpdateFlag(t *testing.T) {
+	defer func() { updateGolden = false }()
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	RegisterUpdateFlag(fs)
+	if err := fs.Parse([]string{"-cmdytest.update"}); err != nil {
+		t.Fatal(err)
+	}
+	if !UpdateGolden() {
+		t.Fatal("expected UpdateGolden")
+	}
+}
+
+type fileCommand struct {
+	in, out string
+}
+
+func (cmd *fileCommand) Help() cmdy.Help { return cmdy.Help{} }
+
+func (cmd *fileCommand) Configure(flags *cmdy.FlagSet, args *arg.ArgSet) {
+	args.String(&cmd.in, "in", "")
+	args.String(&cmd.out, "out", "")
+}
+
+func (cmd *fileCommand) Run(ctx cmdy.Context) error {
+	data, err := ioutil.ReadFile(cmd.in)
+	if err != nil {
+		return err
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(ctx.Stdout(), "%s %s\n", os.Getenv("GREETING"), wd)
+	return ioutil.WriteFile(cmd.out, []byte(strings.ToUpper(string(data))), 0666)
+}
+
+func TestExampleTesterFiles(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bld := func() cmdy.Command { return &fileCommand{} }
+	base := cmdy.Example{
+		Command:     "../../in.txt out.txt",
+		TestMode:    cmdy.ExampleRun,
+		Env:         []string{"GREETING=hello"},
+		Files:       map[string]string{"in.txt": "yep"},
+		Dir:         "sub/dir",
+		Output:      "hello $WORK/sub/dir",
+		OutputMode:  cmdy.ExampleOutputTrimmed,
+		OutputFiles: map[string]string{"sub/dir/out.txt": "YEP"},
+	}
+
+	tester := ExampleTester{TestName: "yep", Builder: bld}
+	if err := tester.RunExample(base); err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after != wd {
+		t.Fatalf("working directory not restored: %q != %q", after, wd)
+	}
+	if _, ok := os.LookupEnv("GREETING"); ok {
+		t.Fatal("env not restored")
+	}
+
+	for idx, mod := range []func(ex *cmdy.Example){
+		func(ex *cmdy.Example) { ex.OutputFiles = map[string]string{"sub/dir/out.txt": "NOPE"} },
+		func(ex *cmdy.Example) { ex.OutputFiles = map[string]string{"missing.txt": ""} },
+		func(ex *cmdy.Example) { ex.OutputFiles = map[string]string{"../escape.txt": ""} },
+		func(ex *cmdy.Example) { ex.Env = []string{"GREETING=hi"} },
+		func(ex *cmdy.Example) { ex.Files = nil },
+		func(ex *cmdy.Example) { ex.Dir = "" },
+		func(ex *cmdy.Example) {
+			// OutputFiles are checked even if stdout isn't:
+			ex.OutputMode = cmdy.ExampleOutputIgnore
+			ex.OutputFiles = map[string]string{"sub/dir/out.txt": "NOPE"}
+		},
+	} {
+		t.Run(fmt.Sprint(idx), func(t *testing.T) {
+			ex := base
+			mod(&ex)
+			if err := tester.RunExample(ex); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestExpandWork(t *testing.T) {
+	os.Setenv("CMDYTEST_EXPAND", "nope")
+	defer os.Unsetenv("CMDYTEST_EXPAND")
+
+	for in, out := range map[string]string{
+		"$WORK/a":          "/work/a",
+		"${WORK}/a":        "/work/a",
+		"$CMDYTEST_EXPAND": "$CMDYTEST_EXPAND",
+		"cost: $$5":        "cost: $5",
+		"$$WORK":           "$WORK",
+	} {
+		if found := expandWork(in, "/work"); found != out {
+			t.Fatalf("expandWork(%q): expected %q, found %q", in, out, found)
+		}
+	}
+}
+
+func TestLineDiff(t *testing.T) {
+	result := lineDiff("a\nb\nc\nd", "a\nc\nx\nd\ne")
+	expected := "--- expected\n+++ actual\n  a\n- b\n  c\n+ x\n  d\n+ e"
+	if result != expected {
+		t.Fatalf("diff did not match:\n%s", result)
+	}
+}
@@ -0,0 +1,271 @@
+package cmdytest
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/rand"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/shabbyrobe/cmdy"
+)
+
+// ArgFuzzer runs randomly generated argument vectors through a cmdy.Builder
+// using cmdy.ExampleParseOnly semantics: flags and args are parsed, Groups
+// dispatch to their subcommands, but no Command's Run method is called except
+// for Groups. Group Before and After hooks are not called.
+//
+// Each vector is checked for the following:
+//
+//   - Nothing panics, including Builders and Configure methods.
+//   - Usage errors (including help requests) always render the command's help
+//     when formatted with cmdy.FormatError.
+//   - Running the same vector twice produces the same exit code and output.
+//
+// Arguments are generated from the names of the subcommands and flags found in
+// the Builder's command tree, mixed with special tokens like '-help' and '--'
+// and random junk.
+//
+// CheckArgs can be used to check a single vector, for example from a native Go
+// fuzz target:
+//
+//	func FuzzArgs(f *testing.F) {
+//		fuzzer := cmdytest.ArgFuzzer{Builder: myBuilder}
+//		f.Fuzz(func(t *testing.T, in string) {
+//			if err := fuzzer.CheckArgs(strings.Split(in, "\x00")); err != nil {
+//				t.Fatal(err)
+//			}
+//		})
+//	}
+//
+// NOTE: this is an experimental API and may change without warning.
+type ArgFuzzer struct {
+	Builder cmdy.Builder
+
+	// Seed for the random generator used by Fuzz. If zero, 1 is used, so
+	// failures are reproducible by default.
+	Seed int64
+
+	// Number of vectors generated by Fuzz. Defaults to 1000, or 100 if
+	// testing.Short() is set.
+	Iterations int
+
+	// Maximum number of arguments in each generated vector. Defaults to 8.
+	MaxArgs int
+}
+
+// Fuzz generates Iterations argument vectors and fails the test on the first
+// vector that does not pass CheckArgs.
+func (f *ArgFuzzer) Fuzz(t *testing.T) {
+	t.Helper()
+
+	seed := f.Seed
+	if seed == 0 {
+		seed = 1
+	}
+	iterations := f.Iterations
+	if iterations <= 0 {
+		iterations = 1000
+		if testing.Short() {
+			iterations = 100
+		}
+	}
+	maxArgs := f.MaxArgs
+	if maxArgs <= 0 {
+		maxArgs = 8
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+	vocab := f.Vocabulary()
+
+	for i := 0; i < iterations; i++ {
+		args := generateArgs(rng, vocab, maxArgs)
+		if err := f.CheckArgs(args); err != nil {
+			t.Fatalf("iteration %d (seed %d): %v", i, seed, err)
+		}
+	}
+}
+
+// CheckArgs runs a single argument vector through the Builder and returns an
+// error describing the problem if any of the ArgFuzzer's checks fail.
+func (f *ArgFuzzer) CheckArgs(args []string) error {
+	first, err := f.run(args)
+	if err != nil {
+		return fmt.Errorf("args %q: %w", args, err)
+	}
+
+	if cmdy.IsUsageError(first.err) {
+		if first.msg == "" || !strings.Contains(first.msg, strings.TrimSpace(cmdy.DefaultMessages().Usage)) {
+			return fmt.Errorf("args %q: usage error did not render help: %q", args, first.msg)
+		}
+	}
+
+	second, err := f.run(args)
+	if err != nil {
+		return fmt.Errorf("args %q: second run: %w", args, err)
+	}
+	if first.code != second.code || first.msg != second.msg ||
+		first.stdout != second.stdout || first.stderr != second.stderr {
+		return fmt.Errorf("args %q: result not deterministic:\nfirst:  %s\nsecond: %s", args, first, second)
+	}
+
+	return nil
+}
+
+// Vocabulary returns the tokens that are used to build argument vectors: the
+// subcommand and flag names found in the Builder's command tree, in sorted order.
+func (f *ArgFuzzer) Vocabulary() []string {
+	words := map[string]bool{}
+	collectVocabulary(f.Builder, words, 0)
+
+	out := make([]string, 0, len(words))
+	for w := range words {
+		out = append(out, w)
+	}
+	sort.Strings(out)
+	return out
+}
+
+type fuzzResult struct {
+	err    error
+	code   int
+	msg    string
+	stdout string
+	stderr string
+}
+
+func (r fuzzResult) String() string {
+	return fmt.Sprintf("code=%d msg=%q stdout=%q stderr=%q", r.code, r.msg, r.stdout, r.stderr)
+}
+
+func (f *ArgFuzzer) run(args []string) (result fuzzResult, rerr error) {
+	defer func() {
+		if r := recover(); r != nil {
+			rerr = fmt.Errorf("panic: %v\n%s", r, debug.Stack())
+		}
+	}()
+
+	runner := cmdy.NewBufferedRunner()
+	runner.Use(parseOnly)
+	err := runner.Run(context.Background(), "fuzz", args, hooklessBuilder(f.Builder))
+	msg, code := cmdy.FormatError(err)
+	return fuzzResult{
+		err:    err,
+		code:   code,
+		msg:    msg,
+		stdout: runner.StdoutBuffer.String(),
+		stderr: runner.StderrBuffer.String(),
+	}, nil
+}
+
+// parseOnly is a Middleware that stops Commands other than Groups from
+// running, so their flags and args are parsed but nothing else happens. The
+// Commands themselves are not wrapped, so optional interfaces like
+// cmdy.HelpSection still work and help is rendered exactly as it would be for
+// the real command.
+func parseOnly(next cmdy.RunFunc) cmdy.RunFunc {
+	return func(ctx cmdy.Context) error {
+		if _, ok := ctx.Current().Command.(*cmdy.Group); ok {
+			return next(ctx)
+		}
+		return nil
+	}
+}
+
+// hooklessBuilder wraps a Builder so that Groups are copied with their Before
+// and After hooks removed.
+func hooklessBuilder(b cmdy.Builder) cmdy.Builder {
+	return func() cmdy.Command {
+		cmd := b()
+		grp, ok := cmd.(*cmdy.Group)
+		if !ok {
+			return cmd
+		}
+		cp := *grp
+		cp.Before, cp.After = nil, nil
+		rewriter := grp.Rewriter
+		cp.Rewriter = func(grp *cmdy.Group, state cmdy.GroupRunState) *cmdy.GroupRunState {
+			if rewriter != nil {
+				if out := rewriter(grp, state); out != nil {
+					state = *out
+				}
+			}
+			if state.Builder != nil {
+				state.Builder = hooklessBuilder(state.Builder)
+			}
+			return &state
+		}
+		return &cp
+	}
+}
+
+// Groups can refer to themselves, directly or indirectly; this stops the
+// vocabulary walk from recursing forever.
+const maxVocabularyDepth = 8
+
+func collectVocabulary(b cmdy.Builder, words map[string]bool, depth int) {
+	if depth > maxVocabularyDepth {
+		return
+	}
+
+	cmd := b()
+	configureFlags(cmd).VisitAll(func(fl *flag.Flag) {
+		words["-"+fl.Name] = true
+		words["--"+fl.Name+"="+fl.DefValue] = true
+	})
+
+	if grp, ok := cmd.(*cmdy.Group); ok {
+		for name, sub := range grp.Builders {
+			words[name] = true
+			collectVocabulary(sub, words, depth+1)
+		}
+	}
+}
+
+var fuzzSpecials = []string{
+	"", "-", "--", "-h", "-help", "--help", "=", "-=", "--=",
+	"0", "-1", "1.5", "true", "false", "1h", "NaN", "18446744073709551616",
+	"'", "\"", "\\", "\x00", "\n", " ", "日本語", "\xff",
+}
+
+func generateArgs(rng *rand.Rand, vocab []string, maxArgs int) []string {
+	n := rng.Intn(maxArgs + 1)
+	args := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		var word string
+		switch p := rng.Intn(10); {
+		case p < 6 && len(vocab) > 0:
+			word = vocab[rng.Intn(len(vocab))]
+		case p < 8:
+			word = fuzzSpecials[rng.Intn(len(fuzzSpecials))]
+		default:
+			word = randomJunk(rng)
+		}
+
+		// Occasionally attach a value or mangle the word:
+		switch rng.Intn(8) {
+		case 0:
+			word += "=" + fuzzSpecials[rng.Intn(len(fuzzSpecials))]
+		case 1:
+			word = strings.ToUpper(word)
+		case 2:
+			if len(word) > 1 {
+				word = word[:rng.Intn(len(word))]
+			}
+		}
+		args = append(args, word)
+	}
+	return args
+}
+
+func randomJunk(rng *rand.Rand) string {
+	const chars = "abcxyz-_=.0123456789 '\"\\\t"
+	b := make([]byte, rng.Intn(8))
+	for i := range b {
+		b[i] = chars[rng.Intn(len(chars))]
+	}
+	return string(b)
+}
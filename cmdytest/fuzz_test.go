@@ -0,0 +1,125 @@
+package cmdytest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/shabbyrobe/cmdy"
+	"github.com/shabbyrobe/cmdy/arg"
+)
+
+type fuzzLeafCommand struct {
+	str  string
+	num  int
+	dur  bool
+	rest []int
+}
+
+func (cmd *fuzzLeafCommand) Help() cmdy.Help { return cmdy.Synopsis("leaf") }
+
+func (cmd *fuzzLeafCommand) Configure(flags *cmdy.FlagSet, args *arg.ArgSet) {
+	flags.StringVar(&cmd.str, "str", "", "string")
+	flags.IntVar(&cmd.num, "num", 1, "int")
+	flags.BoolVar(&cmd.dur, "yep", false, "bool")
+	args.RemainingInts(&cmd.rest, "ints", arg.Range{Min: 1, Max: 3}, "ints")
+}
+
+func (cmd *fuzzLeafCommand) Run(ctx cmdy.Context) error {
+	panic("Run should not be called")
+}
+
+// fuzzArgVal misbehaves on particular inputs, to make sure the fuzzer notices.
+type fuzzArgVal struct {
+	calls *int
+}
+
+func (v fuzzArgVal) String() string { return "" }
+
+func (v fuzzArgVal) Set(s string) error {
+	switch s {
+	case "panic":
+		panic("boom")
+	case "flaky":
+		*v.calls++
+		return fmt.Errorf("call %d", *v.calls)
+	}
+	return nil
+}
+
+type fuzzBadCommand struct {
+	calls *int
+}
+
+func (cmd *fuzzBadCommand) Help() cmdy.Help { return cmdy.Synopsis("bad") }
+
+func (cmd *fuzzBadCommand) Configure(flags *cmdy.FlagSet, args *arg.ArgSet) {
+	args.Var(fuzzArgVal{cmd.calls}, "val", "val")
+}
+
+func (cmd *fuzzBadCommand) Run(ctx cmdy.Context) error { return nil }
+
+func fuzzTestBuilder() cmdy.Command {
+	return cmdy.NewGroup("fuzz", cmdy.Builders{
+		"leaf": func() cmdy.Command { return &fuzzLeafCommand{} },
+		"sub": func() cmdy.Command {
+			return cmdy.NewGroup("sub", cmdy.Builders{
+				"leaf": func() cmdy.Command { return &fuzzLeafCommand{} },
+			}, cmdy.GroupBefore(func(ctx cmdy.Context) error {
+				panic("Before should not be called")
+			}))
+		},
+	}, cmdy.GroupHelpCommand(), cmdy.GroupPrefixMatcher(2))
+}
+
+func TestArgFuzzer(t *testing.T) {
+	fuzzer := ArgFuzzer{Builder: fuzzTestBuilder, Iterations: 500}
+	fuzzer.Fuzz(t)
+}
+
+func TestArgFuzzerVocabulary(t *testing.T) {
+	fuzzer := ArgFuzzer{Builder: fuzzTestBuilder}
+	vocab := strings.Join(fuzzer.Vocabulary(), " ")
+	expected := "--num=1 --str= --yep=false -num -str -yep help leaf sub"
+	if vocab != expected {
+		t.Fatalf("expected %q, found %q", expected, vocab)
+	}
+}
+
+func TestArgFuzzerCheckArgs(t *testing.T) {
+	var calls int
+	fuzzer := ArgFuzzer{Builder: func() cmdy.Command { return &fuzzBadCommand{calls: &calls} }}
+
+	if err := fuzzer.CheckArgs([]string{"ok"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := fuzzer.CheckArgs([]string{"panic"}); err == nil || !strings.Contains(err.Error(), "panic: boom") {
+		t.Fatalf("expected panic error, found %v", err)
+	}
+	if err := fuzzer.CheckArgs([]string{"flaky"}); err == nil || !strings.Contains(err.Error(), "not deterministic") {
+		t.Fatalf("expected determinism error, found %v", err)
+	}
+}
+
+type fuzzSectionCommand struct{}
+
+func (cmd *fuzzSectionCommand) Help() cmdy.Help                                 { return cmdy.Synopsis("section") }
+func (cmd *fuzzSectionCommand) Configure(flags *cmdy.FlagSet, args *arg.ArgSet) {}
+func (cmd *fuzzSectionCommand) Run(ctx cmdy.Context) error                      { return nil }
+
+func (cmd *fuzzSectionCommand) BuildHelp(into *strings.Builder) error {
+	into.WriteString("custom section")
+	return nil
+}
+
+func TestArgFuzzerRealHelp(t *testing.T) {
+	// The help rendered while fuzzing includes the command's own HelpSection:
+	fuzzer := ArgFuzzer{Builder: func() cmdy.Command { return &fuzzSectionCommand{} }}
+	result, err := fuzzer.run([]string{"-help"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(result.msg, "custom section") {
+		t.Fatalf("help section missing from %q", result.msg)
+	}
+}
@@ -0,0 +1,130 @@
+package cmdytest
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/shabbyrobe/cmdy"
+)
+
+var updateGolden bool
+
+// RegisterUpdateFlag registers the '-cmdytest.update' flag with fs, which is
+// usually flag.CommandLine. Passing the flag to 'go test' makes UpdateGolden
+// return true. cmdytest does not register the flag itself; call this from a
+// test file in each package that uses golden files or snapshots:
+//
+//	func init() {
+//		cmdytest.RegisterUpdateFlag(flag.CommandLine)
+//	}
+//
+func RegisterUpdateFlag(fs *flag.FlagSet) {
+	fs.BoolVar(&updateGolden, "cmdytest.update", false, "rewrite golden files used by cmdytest")
+}
+
+// UpdateGolden returns true if golden files should be rewritten rather than
+// compared. It is enabled by passing '-cmdytest.update' to 'go test'; see
+// RegisterUpdateFlag.
+func UpdateGolden() bool {
+	return updateGolden
+}
+
+// matchOutput compares the actual output of a command against the expected
+// output using the given mode. If they don't match, the error contains a
+// human-readable explanation.
+func matchOutput(stream string, mode cmdy.ExampleOutputMode, expected, actual string) error {
+	switch mode {
+	case cmdy.ExampleOutputIgnore:
+		return nil
+
+	case cmdy.ExampleOutputExact:
+		if expected != actual {
+			return fmt.Errorf("%s did not match:\n%s", stream, lineDiff(expected, actual))
+		}
+
+	case cmdy.ExampleOutputTrimmed:
+		expected, actual = strings.TrimSpace(expected), strings.TrimSpace(actual)
+		if expected != actual {
+			return fmt.Errorf("%s did not match:\n%s", stream, lineDiff(expected, actual))
+		}
+
+	case cmdy.ExampleOutputRegexp:
+		ptn, err := regexp.Compile(expected)
+		if err != nil {
+			return fmt.Errorf("%s pattern invalid: %w", stream, err)
+		}
+		if !ptn.MatchString(actual) {
+			return fmt.Errorf("%s did not match pattern:\n%s", stream, showMismatch(expected, actual))
+		}
+
+	case cmdy.ExampleOutputPrefix:
+		if !strings.HasPrefix(actual, expected) {
+			return fmt.Errorf("%s did not start with expected prefix:\n%s", stream, showMismatch(expected, actual))
+		}
+
+	case cmdy.ExampleOutputContains:
+		if !strings.Contains(actual, expected) {
+			return fmt.Errorf("%s did not contain expected text:\n%s", stream, showMismatch(expected, actual))
+		}
+
+	default:
+		return fmt.Errorf("unknown output mode %d", mode)
+	}
+
+	return nil
+}
+
+func showMismatch(expected, actual string) string {
+	return fmt.Sprintf("expected:\n%s\nactual:\n%s", indentLines(expected), indentLines(actual))
+}
+
+func indentLines(s string) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = "    " + line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// goldenFile is a file containing expected output that lives outside the Go
+// source, which can be rewritten when UpdateGolden() is true.
+type goldenFile struct {
+	path   string
+	update bool
+}
+
+// check compares actual with the contents of the golden file, or rewrites
+// the file if update is set. If the file does not exist and update is not set,
+// check fails.
+//
+// Golden files that contain patterns rather than output (i.e. when using
+// ExampleOutputRegexp) are never rewritten.
+func (g goldenFile) check(stream string, mode cmdy.ExampleOutputMode, actual string) error {
+	rewritable := mode == cmdy.ExampleOutputExact || mode == cmdy.ExampleOutputTrimmed
+	if g.update && rewritable {
+		if err := os.MkdirAll(filepath.Dir(g.path), 0777); err != nil {
+			return err
+		}
+		return ioutil.WriteFile(g.path, []byte(actual), 0666)
+	}
+
+	expected, err := ioutil.ReadFile(g.path)
+	if os.IsNotExist(err) {
+		if !rewritable {
+			return fmt.Errorf("missing golden file %s", g.path)
+		}
+		return fmt.Errorf("missing golden file %s; run with -cmdytest.update", g.path)
+	} else if err != nil {
+		return err
+	}
+
+	if err := matchOutput(stream, mode, string(expected), actual); err != nil {
+		return fmt.Errorf("%w\n(golden file: %s; run 'go test -cmdytest.update' to rewrite)", err, g.path)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package cmdytest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// sandbox is a temporary working directory and environment for running
+// commands in-process.
+//
+// The working directory and environment are process-wide, so tests that use
+// a sandbox must not be run in parallel with any other tests.
+type sandbox struct {
+	dir     string
+	origDir string
+	origEnv map[string]*string
+}
+
+func newSandbox() (sb *sandbox, rerr error) {
+	orig, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	dir, err := ioutil.TempDir("", "cmdytest-")
+	if err != nil {
+		return nil, err
+	}
+
+	// On macOS, the temp dir is behind a symlink; resolve it so $WORK matches
+	// what the command sees when it calls os.Getwd():
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = resolved
+	}
+
+	sb = &sandbox{dir: dir, origDir: orig, origEnv: map[string]*string{}}
+	defer func() {
+		if rerr != nil {
+			sb.close()
+		}
+	}()
+
+	if err := os.Chdir(dir); err != nil {
+		return nil, err
+	}
+	if err := sb.setenv("WORK", dir); err != nil {
+		return nil, err
+	}
+	return sb, nil
+}
+
+// path resolves name relative to the sandbox's current working directory. It
+// is an error for the result to be outside the sandbox.
+func (sb *sandbox) path(name string) (string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	return sb.resolve(cwd, name)
+}
+
+// rootPath resolves name relative to the root of the sandbox, regardless of
+// the current working directory.
+func (sb *sandbox) rootPath(name string) (string, error) {
+	return sb.resolve(sb.dir, name)
+}
+
+func (sb *sandbox) resolve(base, name string) (string, error) {
+	if filepath.IsAbs(name) {
+		return "", fmt.Errorf("path %q must be relative", name)
+	}
+	full := filepath.Join(base, filepath.FromSlash(name))
+	rel, err := filepath.Rel(sb.dir, full)
+	if err != nil || rel == ".." || (len(rel) > 2 && rel[:3] == ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path %q is outside the sandbox", name)
+	}
+	return full, nil
+}
+
+func (sb *sandbox) chdir(name string) error {
+	full, err := sb.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(full, 0777); err != nil {
+		return err
+	}
+	return os.Chdir(full)
+}
+
+func (sb *sandbox) writeFile(name string, data []byte) error {
+	full, err := sb.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(full), 0777); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(full, data, 0666)
+}
+
+func (sb *sandbox) readFile(name string) ([]byte, error) {
+	full, err := sb.path(name)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadFile(full)
+}
+
+func (sb *sandbox) setenv(key, value string) error {
+	if _, ok := sb.origEnv[key]; !ok {
+		if orig, ok := os.LookupEnv(key); ok {
+			sb.origEnv[key] = &orig
+		} else {
+			sb.origEnv[key] = nil
+		}
+	}
+	return os.Setenv(key, value)
+}
+
+func (sb *sandbox) close() error {
+	for key, orig := range sb.origEnv {
+		if orig == nil {
+			os.Unsetenv(key)
+		} else {
+			os.Setenv(key, *orig)
+		}
+	}
+	err := os.Chdir(sb.origDir)
+	if rerr := os.RemoveAll(sb.dir); err == nil {
+		err = rerr
+	}
+	return err
+}
@@ -0,0 +1,375 @@
+package cmdytest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/shabbyrobe/cmdy"
+	"github.com/shabbyrobe/cmdy/cmdstr"
+)
+
+/*
+ScriptTester runs transcript-style test scripts against a cmdy.Builder,
+in-process, using a cmdy.BufferedRunner.
+
+Scripts use the txtar format: the script itself is the archive's comment,
+and any files in the archive are written to a temporary working directory
+before the script starts. All commands in a script share the working
+directory and environment. The working directory is available to the script
+in the $WORK environment variable.
+
+	# Lines starting with '#' are comments.
+	env NAME=value
+	cd subdir
+
+	$ tool -flag arg 'quoted arg'
+	< a line of stdin
+	> a line of expected stdout
+	2> a line of expected stderr
+	? 0
+
+	cmp subdir/out.txt expected.txt
+	exists subdir/other.txt
+
+	-- input.txt --
+	File contents
+	-- expected.txt --
+	More file contents
+
+Lines beginning with '$ ' run a command. The first word is the program name
+that is passed to cmdy.Runner.Run; the rest are the arguments, which are
+split using the same rules as cmdy.Example.Command after environment
+variables are expanded.
+
+The '<', '>', '2>' and '?' lines that follow a command describe its stdin,
+expected stdout, expected stderr and expected exit code respectively. A
+space is required after the prefix, unless the line is empty.
+
+Stdout and stderr must match exactly, line by line. A line containing only
+'...' matches zero or more lines. If the command returns an error, the error
+is formatted by cmdy.FormatError and appended to stderr, as cmdy.Fatal
+would do. Occurrences of the working directory in the output are replaced
+with the literal string '$WORK'.
+
+The expected exit code follows the same rules as cmdy.Example.Code. If it
+is omitted, the command must succeed.
+
+The following directives are also available:
+
+	env NAME=value   Set an environment variable.
+	cd dir           Change the working directory, relative to the current one.
+	cmp a b          Compare the contents of two files.
+	exists name...   Check that files exist.
+
+Because the working directory and environment are process-wide, tests that
+use ScriptTester must not run in parallel.
+
+NOTE: this is an experimental API and may change without warning.
+*/
+type ScriptTester struct {
+	Builder cmdy.Builder
+
+	// Env contains additional environment variables in "KEY=value" form
+	// which are set before each script is run.
+	Env []string
+}
+
+// TestScripts runs every script matching the glob pattern as a subtest.
+func (s *ScriptTester) TestScripts(t *testing.T, pattern string) {
+	t.Helper()
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatalf("no scripts matched %q", pattern)
+	}
+	for _, file := range files {
+		s.TestScript(t, file)
+	}
+}
+
+// TestScript runs the script in file as a subtest.
+func (s *ScriptTester) TestScript(t *testing.T, file string) {
+	t.Helper()
+	name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	t.Run(name, func(t *testing.T) {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.RunScript(file, data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+// RunScript runs the script contained in data. name is used in error messages.
+func (s *ScriptTester) RunScript(name string, data []byte) (rerr error) {
+	arc := parseArchive(data)
+	steps, err := parseScript(arc.comment)
+	if err != nil {
+		return fmt.Errorf("%s:%w", name, err)
+	}
+
+	sb, err := newSandbox()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := sb.close(); err != nil && rerr == nil {
+			rerr = err
+		}
+	}()
+
+	for _, kv := range s.Env {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid env %q", kv)
+		}
+		if err := sb.setenv(parts[0], parts[1]); err != nil {
+			return err
+		}
+	}
+
+	for _, f := range arc.files {
+		if err := sb.writeFile(f.name, f.data); err != nil {
+			return err
+		}
+	}
+
+	for _, step := range steps {
+		if err := s.runStep(sb, step); err != nil {
+			return fmt.Errorf("%s:%d: %w", name, step.line, err)
+		}
+	}
+	return nil
+}
+
+type scriptStep struct {
+	line int
+	verb string
+	args []string
+
+	// Only used if verb is "$":
+	command string
+	stdin   []string
+	stdout  []string
+	stderr  []string
+	code    int
+}
+
+func parseScript(script []byte) (steps []*scriptStep, err error) {
+	var last *scriptStep
+
+	for idx, line := range strings.Split(string(script), "\n") {
+		lineNo := idx + 1
+		line = strings.TrimRight(line, "\r")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		if cmd, ok := scriptLine(line, "$"); ok {
+			last = &scriptStep{line: lineNo, verb: "$", command: cmd}
+			steps = append(steps, last)
+			continue
+		}
+
+		var stream string
+		var content string
+		var ok bool
+		for _, prefix := range []string{"<", ">", "2>", "?"} {
+			if content, ok = scriptLine(line, prefix); ok {
+				stream = prefix
+				break
+			}
+		}
+
+		if stream != "" {
+			if last == nil {
+				return nil, fmt.Errorf("%d: %q without command", lineNo, stream)
+			}
+			switch stream {
+			case "<":
+				last.stdin = append(last.stdin, content)
+			case ">":
+				last.stdout = append(last.stdout, content)
+			case "2>":
+				last.stderr = append(last.stderr, content)
+			case "?":
+				if last.code, err = strconv.Atoi(content); err != nil {
+					return nil, fmt.Errorf("%d: invalid exit code %q", lineNo, content)
+				}
+			}
+			continue
+		}
+
+		fields, err := cmdstr.ParseString(trimmed, "")
+		if err != nil {
+			return nil, fmt.Errorf("%d: %w", lineNo, err)
+		}
+		switch fields[0] {
+		case "env", "cd", "cmp", "exists":
+			last = nil
+			steps = append(steps, &scriptStep{line: lineNo, verb: fields[0], args: fields[1:]})
+		default:
+			return nil, fmt.Errorf("%d: unknown directive %q", lineNo, fields[0])
+		}
+	}
+
+	return steps, nil
+}
+
+// scriptLine returns the content following prefix if line starts with the
+// prefix followed by a space, or is exactly the prefix.
+func scriptLine(line string, prefix string) (content string, ok bool) {
+	if line == prefix {
+		return "", true
+	}
+	if strings.HasPrefix(line, prefix+" ") {
+		return line[len(prefix)+1:], true
+	}
+	return "", false
+}
+
+func (s *ScriptTester) runStep(sb *sandbox, step *scriptStep) error {
+	switch step.verb {
+	case "$":
+		return s.runCommand(sb, step)
+
+	case "env":
+		for _, kv := range step.args {
+			parts := strings.SplitN(kv, "=", 2)
+			if len(parts) != 2 {
+				return fmt.Errorf("env: expected NAME=value, found %q", kv)
+			}
+			if err := sb.setenv(parts[0], os.ExpandEnv(parts[1])); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case "cd":
+		if len(step.args) != 1 {
+			return fmt.Errorf("cd: expected 1 argument")
+		}
+		return sb.chdir(os.ExpandEnv(step.args[0]))
+
+	case "cmp":
+		if len(step.args) != 2 {
+			return fmt.Errorf("cmp: expected 2 arguments")
+		}
+		a, err := sb.readFile(os.ExpandEnv(step.args[0]))
+		if err != nil {
+			return err
+		}
+		b, err := sb.readFile(os.ExpandEnv(step.args[1]))
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(a, b) {
+			return fmt.Errorf("cmp: %s and %s differ:\n%s", step.args[0], step.args[1], lineDiff(string(b), string(a)))
+		}
+		return nil
+
+	case "exists":
+		for _, name := range step.args {
+			full, err := sb.path(os.ExpandEnv(name))
+			if err != nil {
+				return err
+			}
+			if _, err := os.Stat(full); err != nil {
+				return fmt.Errorf("exists: %w", err)
+			}
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unknown directive %q", step.verb)
+	}
+}
+
+func (s *ScriptTester) runCommand(sb *sandbox, step *scriptStep) error {
+	args, err := cmdstr.ParseString(os.ExpandEnv(step.command), "")
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("missing program name")
+	}
+
+	runner := cmdy.NewBufferedRunner()
+	if len(step.stdin) > 0 {
+		runner.StdinBuffer.WriteString(strings.Join(step.stdin, "\n") + "\n")
+	}
+
+	runErr := runner.Run(context.Background(), args[0], args[1:], s.Builder)
+	code := cmdy.ErrCode(runErr)
+	if runErr != nil {
+		if msg, _ := cmdy.FormatError(runErr); msg != "" {
+			runner.StderrBuffer.WriteString(msg)
+			runner.StderrBuffer.WriteByte('\n')
+		}
+	}
+
+	// Replace the sandbox path so output is stable between runs:
+	stdout := strings.Replace(runner.StdoutBuffer.String(), sb.dir, "$WORK", -1)
+	stderr := strings.Replace(runner.StderrBuffer.String(), sb.dir, "$WORK", -1)
+
+	if err := matchScriptOutput("stdout", step.stdout, stdout); err != nil {
+		return err
+	}
+	if err := matchScriptOutput("stderr", step.stderr, stderr); err != nil {
+		return err
+	}
+
+	if code == 0 && step.code == 0 {
+		// all good
+	} else if step.code >= 0 && code != step.code {
+		return fmt.Errorf("unexpected code %d, expected %d: %v", code, step.code, runErr)
+	} else if step.code < 0 && code == 0 {
+		return fmt.Errorf("unexpected success, expected non-zero exit code")
+	}
+
+	return nil
+}
+
+func matchScriptOutput(stream string, expected []string, actual string) error {
+	var actualLines []string
+	if actual != "" {
+		actualLines = strings.Split(strings.TrimSuffix(actual, "\n"), "\n")
+	}
+	if !matchLines(expected, actualLines) {
+		return fmt.Errorf("%s did not match:\n%s", stream,
+			lineDiff(strings.Join(expected, "\n"), strings.Join(actualLines, "\n")))
+	}
+	return nil
+}
+
+// matchLines reports whether the actual lines match the expected lines, where
+// an expected line of '...' matches zero or more actual lines.
+func matchLines(expected, actual []string) bool {
+	for len(expected) > 0 {
+		if expected[0] == "..." {
+			for skip := 0; skip <= len(actual); skip++ {
+				if matchLines(expected[1:], actual[skip:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(actual) == 0 || expected[0] != actual[0] {
+			return false
+		}
+		expected, actual = expected[1:], actual[1:]
+	}
+	return len(actual) == 0
+}
@@ -0,0 +1,159 @@
+package cmdytest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/shabbyrobe/cmdy"
+	"github.com/shabbyrobe/cmdy/arg"
+)
+
+// scriptCopyCommand copies stdin or a file to stdout, or to a file with -o.
+type scriptCopyCommand struct {
+	out   string
+	upper bool
+	in    string
+}
+
+func (cmd *scriptCopyCommand) Help() cmdy.Help { return cmdy.Synopsis("copy") }
+
+func (cmd *scriptCopyCommand) Configure(flags *cmdy.FlagSet, args *arg.ArgSet) {
+	flags.StringVar(&cmd.out, "o", "", "output file")
+	flags.BoolVar(&cmd.upper, "upper", false, "uppercase")
+	args.StringOptional(&cmd.in, "in", "", "input file")
+}
+
+func (cmd *scriptCopyCommand) Run(ctx cmdy.Context) error {
+	var data []byte
+	var err error
+	if cmd.in == "" {
+		data, err = ioutil.ReadAll(ctx.Stdin())
+	} else {
+		data, err = ioutil.ReadFile(cmd.in)
+	}
+	if err != nil {
+		return err
+	}
+	if cmd.upper {
+		data = []byte(strings.ToUpper(string(data)))
+	}
+	if cmd.out != "" {
+		return ioutil.WriteFile(cmd.out, data, 0666)
+	}
+	_, err = ctx.Stdout().Write(data)
+	return err
+}
+
+type scriptEnvCommand struct {
+	name string
+}
+
+func (cmd *scriptEnvCommand) Help() cmdy.Help { return cmdy.Synopsis("env") }
+
+func (cmd *scriptEnvCommand) Configure(flags *cmdy.FlagSet, args *arg.ArgSet) {
+	args.String(&cmd.name, "name", "")
+}
+
+func (cmd *scriptEnvCommand) Run(ctx cmdy.Context) error {
+	v, ok := os.LookupEnv(cmd.name)
+	if !ok {
+		fmt.Fprintf(ctx.Stderr(), "%s not set\n", cmd.name)
+		return cmdy.ErrWithCode(3, fmt.Errorf("missing env"))
+	}
+	fmt.Fprintln(ctx.Stdout(), v)
+	return nil
+}
+
+func scriptTestBuilder() cmdy.Command {
+	return cmdy.NewGroup("tool", cmdy.Builders{
+		"copy": func() cmdy.Command { return &scriptCopyCommand{} },
+		"env":  func() cmdy.Command { return &scriptEnvCommand{} },
+	})
+}
+
+func TestScriptTester(t *testing.T) {
+	tester := ScriptTester{
+		Builder: scriptTestBuilder,
+		Env:     []string{"CMDYTEST_SCRIPT=yep"},
+	}
+	tester.TestScripts(t, "testdata/script/*.txt")
+}
+
+func TestScriptTesterFailures(t *testing.T) {
+	for idx, tc := range []struct {
+		script string
+		err    string
+	}{
+		{"$ tool copy\n< foo\n> bar\n", "stdout did not match"},
+		{"$ tool copy\n< foo\n", "stdout did not match"},
+		{"$ tool env NOPE_NOT_SET\n", "stderr did not match"},
+		{"$ tool env NOPE_NOT_SET\n2> ...\n? 4\n", "unexpected code 3, expected 4"},
+		{"$ tool copy\n? -1\n", "unexpected success"},
+		{"> orphan\n", "without command"},
+		{"$ tool\n? x\n", "invalid exit code"},
+		{"bogus\n", "unknown directive"},
+		{"cmp a.txt b.txt\n-- a.txt --\nfoo\n-- b.txt --\nbar\n", "a.txt and b.txt differ"},
+		{"exists nope.txt\n", "exists:"},
+		{"cd ..\n", "outside the sandbox"},
+	} {
+		t.Run(fmt.Sprint(idx), func(t *testing.T) {
+			tester := ScriptTester{Builder: scriptTestBuilder}
+			err := tester.RunScript("test", []byte(tc.script))
+			if err == nil {
+				t.Fatalf("expected error containing %q, found nil", tc.err)
+			}
+			if !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("expected error containing %q, found %q", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestScriptTesterRestoresEnvAndDir(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tester := ScriptTester{Builder: scriptTestBuilder}
+	if err := tester.RunScript("test", []byte("env CMDYTEST_RESTORE=1\ncd sub\n")); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := os.LookupEnv("CMDYTEST_RESTORE"); ok {
+		t.Fatal("env not restored")
+	}
+	if _, ok := os.LookupEnv("WORK"); ok {
+		t.Fatal("WORK not restored")
+	}
+	after, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after != wd {
+		t.Fatalf("working directory not restored: %q != %q", after, wd)
+	}
+}
+
+func TestMatchLines(t *testing.T) {
+	for idx, tc := range []struct {
+		expected, actual []string
+		match            bool
+	}{
+		{nil, nil, true},
+		{[]string{"..."}, nil, true},
+		{[]string{"..."}, []string{"a", "b"}, true},
+		{[]string{"a", "..."}, []string{"a", "b"}, true},
+		{[]string{"...", "b"}, []string{"a", "b"}, true},
+		{[]string{"a", "...", "c"}, []string{"a", "c"}, true},
+		{[]string{"a", "...", "c"}, []string{"a", "b", "b", "c"}, true},
+		{[]string{"a", "...", "c"}, []string{"a", "b"}, false},
+		{[]string{"a"}, []string{"a", "b"}, false},
+		{[]string{"a", "b"}, []string{"a"}, false},
+	} {
+		if found := matchLines(tc.expected, tc.actual); found != tc.match {
+			t.Fatalf("%d: expected %v, found %v", idx, tc.match, found)
+		}
+	}
+}
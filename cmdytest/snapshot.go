@@ -0,0 +1,335 @@
+package cmdytest
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/shabbyrobe/cmdy"
+	"github.com/shabbyrobe/cmdy/arg"
+)
+
+// HelpSnapshot compares the help for every command in a tree against a
+// directory of snapshot files, so that accidental changes to the command line
+// interface show up in tests and in code review.
+//
+// The tree is walked from Builder through each cmdy.Group's Builders,
+// including hidden ones. The help for each command is rendered exactly as a
+// user would see it when passing '-help' to the command, and is stored in a
+// file named after the command's path, e.g. 'tool.sub.cmd.txt'. Characters in
+// command names that can't be used in a file name, or that would make two
+// paths share a file name (like '.' and '/'), are percent-encoded, so
+// 'tool sub v1.0' is stored in 'tool.sub.v1%2E0.txt'. An index of
+// all commands and their flags is stored in 'index.txt'; commands and flags
+// which are added to or removed from the index are reported separately from
+// changes to the help text.
+//
+// Group Before and After hooks are not called while the help is rendered. If
+// the tree is more than 8 levels deep, which usually means a Group contains
+// itself, Check returns an error.
+//
+// Pass '-cmdytest.update' to 'go test' (see RegisterUpdateFlag), or set
+// Update, to write the snapshots
+// instead of comparing them. Snapshot files for commands which no longer exist
+// are removed.
+//
+// NOTE: this is an experimental API and may change without warning.
+type HelpSnapshot struct {
+	Builder cmdy.Builder
+
+	// Name of the program, used as the first element of each command path.
+	// Defaults to "cmd".
+	Name string
+
+	// Dir contains the snapshot files.
+	Dir string
+
+	Update bool
+}
+
+const helpSnapshotIndex = "index.txt"
+
+// Test fails the test if the help for any command differs from the snapshot.
+func (h *HelpSnapshot) Test(t *testing.T) {
+	t.Helper()
+	if err := h.Check(); err != nil {
+		t.Fatalf("%v\n(snapshot dir: %s; run 'go test -cmdytest.update' to rewrite)", err, h.Dir)
+	}
+}
+
+// Check compares the help for each command against the snapshot, or rewrites
+// the snapshot if Update is set. If the help differs, the error is a
+// *HelpSnapshotError.
+func (h *HelpSnapshot) Check() error {
+	entries, err := h.collect()
+	if err != nil {
+		return err
+	}
+
+	index := helpSnapshotIndexLines(entries)
+
+	if h.Update || UpdateGolden() {
+		return h.write(entries, index)
+	}
+
+	var serr HelpSnapshotError
+
+	existing, err := ioutil.ReadFile(filepath.Join(h.Dir, helpSnapshotIndex))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	serr.Added, serr.Removed = compareIndex(splitLines(string(existing)), index)
+
+	for _, entry := range entries {
+		expected, err := ioutil.ReadFile(filepath.Join(h.Dir, entry.file))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if string(expected) != entry.help {
+			serr.Changed = append(serr.Changed, entry.name)
+			serr.diffs = append(serr.diffs, lineDiff(string(expected), entry.help))
+		}
+	}
+
+	if len(serr.Added) > 0 || len(serr.Removed) > 0 || len(serr.Changed) > 0 {
+		return &serr
+	}
+	return nil
+}
+
+// HelpSnapshotError describes the differences between the command tree and
+// the snapshot.
+//
+// Added and Removed contain lines from the index, i.e. a command path, or a
+// command path followed by a flag:
+//
+//	tool sub cmd
+//	tool sub cmd -flag
+//
+type HelpSnapshotError struct {
+	Added   []string
+	Removed []string
+
+	// Command paths whose help differs from the snapshot.
+	Changed []string
+
+	diffs []string
+}
+
+func (e *HelpSnapshotError) Error() string {
+	var sb strings.Builder
+	sb.WriteString("help snapshot did not match")
+	for _, line := range e.Added {
+		sb.WriteString("\nadded: ")
+		sb.WriteString(line)
+	}
+	for _, line := range e.Removed {
+		sb.WriteString("\nremoved: ")
+		sb.WriteString(line)
+	}
+	for i, name := range e.Changed {
+		sb.WriteString("\nhelp changed: ")
+		sb.WriteString(name)
+		sb.WriteByte('\n')
+		sb.WriteString(e.diffs[i])
+	}
+	return sb.String()
+}
+
+type helpSnapshotEntry struct {
+	name  string
+	file  string
+	flags []string
+	help  string
+}
+
+func (h *HelpSnapshot) name() string {
+	if h.Name == "" {
+		return "cmd"
+	}
+	return h.Name
+}
+
+func (h *HelpSnapshot) collect() (entries []helpSnapshotEntry, err error) {
+	var walk func(b cmdy.Builder, path []string) error
+	walk = func(b cmdy.Builder, path []string) error {
+		if len(path) > maxVocabularyDepth {
+			return fmt.Errorf("help snapshot: command %q is more than %d levels deep; does a group contain itself?",
+				strings.Join(append([]string{h.name()}, path...), " "), maxVocabularyDepth)
+		}
+
+		cmd := b()
+		var flags []string
+		configureFlags(cmd).VisitAll(func(fl *flag.Flag) {
+			flags = append(flags, "-"+fl.Name)
+		})
+
+		help, err := h.render(path)
+		if err != nil {
+			return err
+		}
+
+		full := append([]string{h.name()}, path...)
+		entries = append(entries, helpSnapshotEntry{
+			name:  strings.Join(full, " "),
+			file:  helpSnapshotFile(full),
+			flags: flags,
+			help:  help,
+		})
+
+		if grp, ok := cmd.(*cmdy.Group); ok {
+			names := make([]string, 0, len(grp.Builders))
+			for name := range grp.Builders {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				sub := append(append([]string{}, path...), name)
+				if err := walk(grp.Builders[name], sub); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	if err := walk(h.Builder, nil); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (h *HelpSnapshot) render(path []string) (string, error) {
+	args := append(append([]string{}, path...), "-help")
+	runner := cmdy.NewBufferedRunner()
+
+	// The commands themselves are used rather than wrapping them like
+	// ArgFuzzer does, so any optional interfaces used by the help (like
+	// cmdy.HelpSection) are still found. '-help' stops the Runner before
+	// any command is run:
+	err := runner.Run(context.Background(), h.name(), args, hooklessBuilder(h.Builder))
+	if !cmdy.IsHelpRequest(err) {
+		return "", fmt.Errorf("help not rendered for %q: %v", strings.Join(args, " "), err)
+	}
+	msg, _ := cmdy.FormatError(err)
+	return msg + "\n", nil
+}
+
+func (h *HelpSnapshot) write(entries []helpSnapshotEntry, index []string) error {
+	if err := os.MkdirAll(h.Dir, 0777); err != nil {
+		return err
+	}
+
+	keep := map[string]bool{helpSnapshotIndex: true}
+	for _, entry := range entries {
+		keep[entry.file] = true
+		if err := ioutil.WriteFile(filepath.Join(h.Dir, entry.file), []byte(entry.help), 0666); err != nil {
+			return err
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(h.Dir, helpSnapshotIndex), []byte(strings.Join(index, "\n")+"\n"), 0666); err != nil {
+		return err
+	}
+
+	// Only remove files that look like they were written by us:
+	infos, err := ioutil.ReadDir(h.Dir)
+	if err != nil {
+		return err
+	}
+	prefix := escapeSnapshotName(h.name()) + "."
+	for _, info := range infos {
+		file := info.Name()
+		if info.Mode().IsRegular() && !keep[file] && strings.HasPrefix(file, prefix) && strings.HasSuffix(file, ".txt") {
+			if err := os.Remove(filepath.Join(h.Dir, file)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// helpSnapshotFile returns the name of the snapshot file for a command path.
+// Each name is escaped, so the file is always in the snapshot directory, and
+// each path has its own file.
+func helpSnapshotFile(path []string) string {
+	escaped := make([]string, len(path))
+	for i, name := range path {
+		escaped[i] = escapeSnapshotName(name)
+	}
+	return strings.Join(escaped, ".") + ".txt"
+}
+
+// escapeSnapshotName percent-encodes the separator '.', '%', control
+// characters, and characters that are not allowed in file names on some
+// operating systems.
+func escapeSnapshotName(name string) string {
+	var sb strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c < 0x20 || c == 0x7f || strings.IndexByte(`%./\:*?"<>|`, c) >= 0 {
+			fmt.Fprintf(&sb, "%%%02X", c)
+		} else {
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+func helpSnapshotIndexLines(entries []helpSnapshotEntry) []string {
+	var lines []string
+	for _, entry := range entries {
+		lines = append(lines, entry.name)
+		for _, fl := range entry.flags {
+			lines = append(lines, entry.name+" "+fl)
+		}
+	}
+	return lines
+}
+
+func compareIndex(expected, actual []string) (added, removed []string) {
+	had := make(map[string]bool, len(expected))
+	for _, line := range expected {
+		had[line] = true
+	}
+	has := make(map[string]bool, len(actual))
+	for _, line := range actual {
+		has[line] = true
+		if !had[line] {
+			added = append(added, line)
+		}
+	}
+	for _, line := range expected {
+		if !has[line] {
+			removed = append(removed, line)
+		}
+	}
+	return added, removed
+}
+
+func splitLines(s string) []string {
+	s = strings.TrimRight(s, "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// configureFlags configures cmd and returns its FlagSet, the same way the
+// Runner would before running it.
+func configureFlags(cmd cmdy.Command) *cmdy.FlagSet {
+	var flags *cmdy.FlagSet
+	if fcmd, ok := cmd.(interface{ Flags() *cmdy.FlagSet }); ok {
+		flags = fcmd.Flags()
+	}
+	if flags == nil {
+		flags = cmdy.NewFlagSet()
+	}
+	cmd.Configure(flags, arg.NewArgSet())
+	return flags
+}
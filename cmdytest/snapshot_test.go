@@ -0,0 +1,207 @@
+package cmdytest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/shabbyrobe/cmdy"
+	"github.com/shabbyrobe/cmdy/arg"
+)
+
+type snapshotCommand struct {
+	extraFlag bool
+	val       string
+}
+
+func (cmd *snapshotCommand) Help() cmdy.Help { return cmdy.Synopsis("snapshot") }
+
+func (cmd *snapshotCommand) Configure(flags *cmdy.FlagSet, args *arg.ArgSet) {
+	flags.StringVar(&cmd.val, "val", "", "value")
+	if cmd.extraFlag {
+		flags.StringVar(&cmd.val, "extra", "", "extra")
+	}
+}
+
+func (cmd *snapshotCommand) Run(ctx cmdy.Context) error { return nil }
+
+type snapshotSectionCommand struct{ snapshotCommand }
+
+func (cmd *snapshotSectionCommand) BuildHelp(into *strings.Builder) error {
+	into.WriteString("Custom section\n")
+	return nil
+}
+
+func TestHelpSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var extraFlag, extraCommand bool
+	bld := func() cmdy.Command {
+		builders := cmdy.Builders{
+			"leaf": func() cmdy.Command { return &snapshotCommand{extraFlag: extraFlag} },
+			"sub": func() cmdy.Command {
+				return cmdy.NewGroup("sub", cmdy.Builders{
+					"leaf": func() cmdy.Command { return &snapshotCommand{} },
+				})
+			},
+		}
+		if extraCommand {
+			builders["extra"] = func() cmdy.Command { return &snapshotCommand{} }
+		}
+		return cmdy.NewGroup("tool", builders)
+	}
+
+	snapshot := HelpSnapshot{Builder: bld, Name: "tool", Dir: dir}
+
+	// Nothing written yet, so everything is added:
+	err = snapshot.Check()
+	serr, ok := err.(*HelpSnapshotError)
+	if !ok {
+		t.Fatal("expected *HelpSnapshotError, found", err)
+	}
+	expected := []string{"tool", "tool leaf", "tool leaf -val", "tool sub", "tool sub leaf", "tool sub leaf -val"}
+	if !reflect.DeepEqual(serr.Added, expected) {
+		t.Fatalf("expected added %q, found %q", expected, serr.Added)
+	}
+
+	snapshot.Update = true
+	if err := snapshot.Check(); err != nil {
+		t.Fatal(err)
+	}
+	snapshot.Update = false
+	if err := snapshot.Check(); err != nil {
+		t.Fatal(err)
+	}
+
+	help, err := ioutil.ReadFile(filepath.Join(dir, "tool.sub.leaf.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(help), "Usage: tool sub leaf") {
+		t.Fatalf("unexpected help:\n%s", help)
+	}
+
+	extraFlag, extraCommand = true, true
+	err = snapshot.Check()
+	serr, ok = err.(*HelpSnapshotError)
+	if !ok {
+		t.Fatal("expected *HelpSnapshotError, found", err)
+	}
+	if expected := []string{"tool extra", "tool extra -val", "tool leaf -extra"}; !reflect.DeepEqual(serr.Added, expected) {
+		t.Fatalf("expected added %q, found %q", expected, serr.Added)
+	}
+	if expected := []string{"tool", "tool extra", "tool leaf"}; !reflect.DeepEqual(serr.Changed, expected) {
+		t.Fatalf("expected changed %q, found %q", expected, serr.Changed)
+	}
+	if !strings.Contains(serr.Error(), "+   -extra=<string>") {
+		t.Fatalf("expected diff in error, found:\n%s", serr.Error())
+	}
+
+	snapshot.Update = true
+	if err := snapshot.Check(); err != nil {
+		t.Fatal(err)
+	}
+
+	extraFlag, extraCommand = false, false
+	snapshot.Update = false
+	serr, ok = snapshot.Check().(*HelpSnapshotError)
+	if !ok {
+		t.Fatal("expected *HelpSnapshotError")
+	}
+	if expected := []string{"tool extra", "tool extra -val", "tool leaf -extra"}; !reflect.DeepEqual(serr.Removed, expected) {
+		t.Fatalf("expected removed %q, found %q", expected, serr.Removed)
+	}
+
+	snapshot.Update = true
+	if err := snapshot.Check(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "tool.extra.txt")); !os.IsNotExist(err) {
+		t.Fatal("expected stale snapshot to be removed", err)
+	}
+}
+
+func TestHelpSnapshotHelpSection(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	bld := func() cmdy.Command {
+		return cmdy.NewGroup("tool", cmdy.Builders{
+			"leaf": func() cmdy.Command { return &snapshotSectionCommand{} },
+		})
+	}
+	snapshot := HelpSnapshot{Builder: bld, Name: "tool", Dir: dir, Update: true}
+	if err := snapshot.Check(); err != nil {
+		t.Fatal(err)
+	}
+
+	help, err := ioutil.ReadFile(filepath.Join(dir, "tool.leaf.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(help), "Custom section") {
+		t.Fatalf("expected custom help section, found:\n%s", help)
+	}
+}
+
+func TestHelpSnapshotTooDeep(t *testing.T) {
+	var bld cmdy.Builder
+	bld = func() cmdy.Command {
+		return cmdy.NewGroup("loop", cmdy.Builders{"loop": bld})
+	}
+	snapshot := HelpSnapshot{Builder: bld, Name: "tool", Dir: "unused"}
+	err := snapshot.Check()
+	if err == nil || !strings.Contains(err.Error(), "levels deep") {
+		t.Fatal("expected depth error, found", err)
+	}
+}
+
+func TestHelpSnapshotFileNames(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// 'a.b' must not share a file with the path 'a b', and '../x' must not
+	// escape the snapshot directory:
+	bld := func() cmdy.Command {
+		return cmdy.NewGroup("tool", cmdy.Builders{
+			"a.b": func() cmdy.Command { return &snapshotCommand{} },
+			"a": func() cmdy.Command {
+				return cmdy.NewGroup("a", cmdy.Builders{
+					"b": func() cmdy.Command { return &snapshotCommand{} },
+				})
+			},
+			"../x": func() cmdy.Command { return &snapshotCommand{} },
+		})
+	}
+	snapshot := HelpSnapshot{Builder: bld, Name: "tool", Dir: dir, Update: true}
+	if err := snapshot.Check(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range []string{"tool.a%2Eb.txt", "tool.a.b.txt", "tool.%2E%2E%2Fx.txt"} {
+		if _, err := os.Stat(filepath.Join(dir, file)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "x.txt")); !os.IsNotExist(err) {
+		t.Fatal("snapshot written outside dir", err)
+	}
+
+	snapshot.Update = false
+	if err := snapshot.Check(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,66 @@
+package cmdytest
+
+import (
+	"bytes"
+	"strings"
+)
+
+// archive is a minimal implementation of the txtar format used by the Go
+// tools for test scripts: a comment, followed by zero or more files, each
+// introduced by a line of the form '-- name --'.
+//
+// See https://pkg.go.dev/golang.org/x/tools/txtar; this implementation
+// exists to avoid the dependency.
+type archive struct {
+	comment []byte
+	files   []archiveFile
+}
+
+type archiveFile struct {
+	name string
+	data []byte
+}
+
+func parseArchive(data []byte) *archive {
+	var arc archive
+	var cur *archiveFile
+	var buf []byte
+
+	flush := func() {
+		if cur == nil {
+			arc.comment = buf
+		} else {
+			cur.data = buf
+			arc.files = append(arc.files, *cur)
+		}
+		buf = nil
+	}
+
+	for len(data) > 0 {
+		var line []byte
+		if idx := bytes.IndexByte(data, '\n'); idx >= 0 {
+			line, data = data[:idx+1], data[idx+1:]
+		} else {
+			line, data = data, nil
+		}
+
+		if name, ok := archiveMarker(line); ok {
+			flush()
+			cur = &archiveFile{name: name}
+			continue
+		}
+		buf = append(buf, line...)
+	}
+	flush()
+
+	return &arc
+}
+
+func archiveMarker(line []byte) (name string, ok bool) {
+	s := strings.TrimRight(string(line), "\r\n")
+	if !strings.HasPrefix(s, "-- ") || !strings.HasSuffix(s, " --") || len(s) < 7 {
+		return "", false
+	}
+	name = strings.TrimSpace(s[3 : len(s)-3])
+	return name, name != ""
+}
@@ -0,0 +1,305 @@
+package cmdyutil
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/shabbyrobe/cmdy"
+	"github.com/shabbyrobe/cmdy/arg"
+	"github.com/shabbyrobe/cmdy/cmdstr"
+)
+
+type BatchErrorPolicy int
+
+const (
+	// Stop running commands after the first command that fails.
+	BatchStopOnError BatchErrorPolicy = iota
+
+	// Run every command, regardless of whether earlier commands failed.
+	BatchContinue
+)
+
+// BatchResult reports the outcome of a single command run by a Batch.
+type BatchResult struct {
+	// 1-based line number of the start of the command in the input.
+	Line int
+
+	Args []string
+	Code int
+
+	// Err is the error returned by the command, or the error encountered
+	// when parsing it.
+	Err error
+}
+
+// Batch runs commands read from a file through a Builder, using a single
+// process. Each line of the input is parsed using the same rules as a Shell:
+// blank lines and lines starting with '#' are ignored, and commands may span
+// multiple lines if they contain a quoted string or end with a backslash.
+//
+// Each command is run with an empty stdin. If a command fails, the error is
+// formatted using cmdy.FormatError and printed to the Runner's Stderr, prefixed
+// with the line number.
+//
+// If Workers is greater than 1, up to that many commands are run at once. The
+// output of each command is buffered, then written to the Runner's Stdout and
+// Stderr in the same order as the input, so the output is the same as if the
+// commands had been run one at a time. Commands run in parallel share the
+// process, so they must not modify global state.
+//
+// NOTE: This API is experimental.
+type Batch struct {
+	Builder cmdy.Builder
+
+	// Runner used to run each command. If nil, cmdy.DefaultRunner() is used.
+	Runner *cmdy.Runner
+
+	// Name passed to Runner.Run for each command. Defaults to cmdy.ProgName().
+	Name string
+
+	OnError BatchErrorPolicy
+	Workers int
+
+	// OnResult, if set, is called with the result of each command, in the same
+	// order as the input, after the command's output has been written.
+	OnResult func(result BatchResult)
+}
+
+// BatchError is returned by Batch.Run if any commands failed. Its Code is the
+// code of the first command that failed.
+type BatchError struct {
+	Failed []BatchResult
+}
+
+func (b *BatchError) Code() int { return b.Failed[0].Code }
+
+func (b *BatchError) Error() string {
+	if len(b.Failed) == 1 {
+		return fmt.Sprintf("batch command at line %d failed", b.Failed[0].Line)
+	}
+	return fmt.Sprintf("%d batch commands failed, starting at line %d", len(b.Failed), b.Failed[0].Line)
+}
+
+// Run reads commands from input and runs them. It returns the results of
+// every command that was run, in the same order as the input. If any command
+// fails, err is a *BatchError.
+//
+// If BatchStopOnError is used, results for commands after the first failure are
+// not returned, even if they were run in parallel with it.
+func (b *Batch) Run(ctx context.Context, input io.Reader) (results []BatchResult, err error) {
+	runner := b.Runner
+	if runner == nil {
+		runner = cmdy.DefaultRunner()
+	}
+
+	jobs, err := readBatchJobs(input)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var failed []BatchResult
+	var stopped bool
+
+	report := func(job *batchJob) {
+		results = append(results, job.result)
+		if b.OnResult != nil {
+			b.OnResult(job.result)
+		}
+		if job.result.Err != nil {
+			failed = append(failed, job.result)
+			if b.OnError == BatchStopOnError {
+				stopped = true
+				cancel()
+			}
+		}
+	}
+
+	if b.Workers <= 1 {
+		// In sequential mode, output is not buffered so long-running commands
+		// can show their progress:
+		for _, job := range jobs {
+			if stopped || ctx.Err() != nil {
+				break
+			}
+			job.runner = b.jobRunner(runner, runner.Stdout, runner.Stderr)
+			b.runJob(ctx, job)
+			report(job)
+		}
+
+	} else {
+		sem := make(chan struct{}, b.Workers)
+		go func() {
+			for _, job := range jobs {
+				select {
+				case sem <- struct{}{}:
+				case <-ctx.Done():
+					job.skipped = true
+					close(job.done)
+					continue
+				}
+				job.runner = b.jobRunner(runner, &job.stdout, &job.stderr)
+				go func(job *batchJob) {
+					defer func() { <-sem }()
+					defer close(job.done)
+					b.runJob(ctx, job)
+				}(job)
+			}
+		}()
+
+		// Remaining jobs are cancelled when we stop, but we still wait for
+		// them to finish so nothing is left running when we return:
+		for _, job := range jobs {
+			<-job.done
+			if job.skipped || stopped {
+				continue
+			}
+			runner.Stdout.Write(job.stdout.Bytes())
+			runner.Stderr.Write(job.stderr.Bytes())
+			report(job)
+		}
+	}
+
+	if len(failed) > 0 {
+		return results, &BatchError{Failed: failed}
+	}
+	return results, ctx.Err()
+}
+
+type batchJob struct {
+	result  BatchResult
+	runner  *cmdy.Runner
+	stdout  bytes.Buffer
+	stderr  bytes.Buffer
+	done    chan struct{}
+	skipped bool
+}
+
+func readBatchJobs(input io.Reader) (jobs []*batchJob, err error) {
+	scanner := bufio.NewScanner(input)
+	cr := commandReader{readLine: func(continuation bool) (string, error) {
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				return "", err
+			}
+			return "", io.EOF
+		}
+		return strings.TrimRight(scanner.Text(), "\r"), nil
+	}}
+
+	for {
+		_, args, line, err := cr.readCommand()
+		if err == io.EOF {
+			return jobs, nil
+		}
+		job := &batchJob{done: make(chan struct{}), result: BatchResult{Line: line, Args: args}}
+		if err != nil {
+			if !errors.Is(err, cmdstr.ErrIncompleteCommand) {
+				return nil, err
+			}
+			// The line number is reported separately:
+			job.result.Err = cmdy.UsageError(cmdstr.ErrIncompleteCommand)
+		}
+		jobs = append(jobs, job)
+	}
+}
+
+func (b *Batch) jobRunner(base *cmdy.Runner, stdout, stderr io.Writer) *cmdy.Runner {
+	r := *base
+	r.Stdin = strings.NewReader("")
+	r.Stdout = stdout
+	r.Stderr = stderr
+	return &r
+}
+
+func (b *Batch) runJob(ctx context.Context, job *batchJob) {
+	name := b.Name
+	if name == "" {
+		name = cmdy.ProgName()
+	}
+
+	err := job.result.Err
+	if err == nil {
+		if err = ctx.Err(); err == nil {
+			err = job.runner.Run(ctx, name, job.result.Args, b.Builder)
+		}
+	}
+
+	job.result.Err = err
+	if err != nil {
+		msg, code := job.runner.FormatError(err)
+		job.result.Code = code
+		if code == 0 {
+			// Help requests and QuietExit(0) are not failures:
+			job.result.Err = nil
+		}
+		if msg != "" {
+			fmt.Fprintf(job.runner.Stderr, "line %d: %s\n", job.result.Line, msg)
+		}
+	}
+}
+
+// BatchCommand returns a Builder for a command that runs a Batch against root,
+// reading commands from a file, or from stdin if the file is '-'.
+//
+// The command uses the Runner it is run with, and the name of the first command
+// on the stack as the Batch's Name.
+//
+// NOTE: This API is experimental.
+func BatchCommand(root cmdy.Builder) cmdy.Builder {
+	return func() cmdy.Command {
+		return &batchCommand{root: root}
+	}
+}
+
+type batchCommand struct {
+	root    cmdy.Builder
+	file    string
+	cont    bool
+	workers int
+	verbose bool
+}
+
+func (bc *batchCommand) Help() cmdy.Help {
+	return cmdy.Synopsis("Run commands from a file")
+}
+
+func (bc *batchCommand) Configure(flags *cmdy.FlagSet, args *arg.ArgSet) {
+	flags.BoolVar(&bc.cont, "k", false, "Keep going after a command fails")
+	flags.IntVar(&bc.workers, "j", 1, "Number of commands to run in parallel")
+	flags.BoolVar(&bc.verbose, "v", false, "Report the exit code of every command to stderr")
+	args.String(&bc.file, "file", "File containing commands, or '-' for stdin")
+}
+
+func (bc *batchCommand) Run(ctx cmdy.Context) error {
+	rdr, err := OpenStdinOrFile(ctx, bc.file, HyphenStdin)
+	if err != nil {
+		return err
+	}
+	defer rdr.Close()
+
+	batch := Batch{
+		Builder: bc.root,
+		Runner:  ctx.Runner(),
+		Name:    ctx.Stack()[0].Name,
+		Workers: bc.workers,
+	}
+	if bc.cont {
+		batch.OnError = BatchContinue
+	}
+	if bc.verbose {
+		batch.OnResult = func(result BatchResult) {
+			fmt.Fprintf(ctx.Stderr(), "line %d: exit %d\n", result.Line, result.Code)
+		}
+	}
+
+	_, err = batch.Run(ctx, rdr)
+	return err
+}
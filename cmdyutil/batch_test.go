@@ -0,0 +1,192 @@
+package cmdyutil
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/shabbyrobe/cmdy"
+	"github.com/shabbyrobe/cmdy/arg"
+)
+
+type batchTestCommand struct {
+	code    int
+	sleep   time.Duration
+	msg     string
+	running *int32
+	maxSeen *int32
+}
+
+func (cmd *batchTestCommand) Help() cmdy.Help { return cmdy.Synopsis("batch test") }
+
+func (cmd *batchTestCommand) Configure(flags *cmdy.FlagSet, args *arg.ArgSet) {
+	flags.IntVar(&cmd.code, "code", 0, "exit code")
+	flags.DurationVar(&cmd.sleep, "sleep", 0, "sleep")
+	args.String(&cmd.msg, "msg", "message")
+}
+
+func (cmd *batchTestCommand) Run(ctx cmdy.Context) error {
+	n := atomic.AddInt32(cmd.running, 1)
+	defer atomic.AddInt32(cmd.running, -1)
+	for {
+		max := atomic.LoadInt32(cmd.maxSeen)
+		if n <= max || atomic.CompareAndSwapInt32(cmd.maxSeen, max, n) {
+			break
+		}
+	}
+
+	select {
+	case <-time.After(cmd.sleep):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	fmt.Fprintln(ctx.Stdout(), cmd.msg)
+	if cmd.code != 0 {
+		return cmdy.ErrWithCode(cmd.code, fmt.Errorf("failed %s", cmd.msg))
+	}
+	return nil
+}
+
+func batchTestBuilder(running, maxSeen *int32) cmdy.Builder {
+	return func() cmdy.Command {
+		return cmdy.NewGroup("test", cmdy.Builders{
+			"run": func() cmdy.Command { return &batchTestCommand{running: running, maxSeen: maxSeen} },
+		})
+	}
+}
+
+const batchTestInput = `
+# comment
+run one
+run -code 3 two
+
+run "three
+lines" 
+run -code 4 four
+run five
+`
+
+func TestBatchStopOnError(t *testing.T) {
+	for _, workers := range []int{1, 4} {
+		t.Run(fmt.Sprint(workers), func(t *testing.T) {
+			var running, maxSeen int32
+			runner := cmdy.NewBufferedRunner()
+			batch := Batch{Builder: batchTestBuilder(&running, &maxSeen), Runner: &runner.Runner, Name: "test", Workers: workers}
+
+			results, err := batch.Run(context.Background(), strings.NewReader(batchTestInput))
+			if cmdy.ErrCode(err) != 3 {
+				t.Fatalf("expected code 3, found %v", err)
+			}
+
+			if len(results) != 2 || results[0].Line != 3 || results[0].Code != 0 || results[1].Line != 4 || results[1].Code != 3 {
+				t.Fatalf("unexpected results %+v", results)
+			}
+			if out := runner.StdoutBuffer.String(); out != "one\ntwo\n" {
+				t.Fatalf("unexpected stdout %q", out)
+			}
+			if stderr := runner.StderrBuffer.String(); stderr != "line 4: failed two\n" {
+				t.Fatalf("unexpected stderr %q", stderr)
+			}
+		})
+	}
+}
+
+func TestBatchContinue(t *testing.T) {
+	for _, workers := range []int{1, 4} {
+		t.Run(fmt.Sprint(workers), func(t *testing.T) {
+			var running, maxSeen int32
+			runner := cmdy.NewBufferedRunner()
+			batch := Batch{
+				Builder: batchTestBuilder(&running, &maxSeen),
+				Runner:  &runner.Runner,
+				Name:    "test",
+				Workers: workers,
+				OnError: BatchContinue,
+			}
+
+			var reported []int
+			batch.OnResult = func(result BatchResult) { reported = append(reported, result.Code) }
+
+			_, err := batch.Run(context.Background(), strings.NewReader(batchTestInput))
+			berr, ok := err.(*BatchError)
+			if !ok || len(berr.Failed) != 2 || berr.Code() != 3 {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if expected := []int{0, 3, 0, 4, 0}; !reflect.DeepEqual(expected, reported) {
+				t.Fatalf("expected codes %v, found %v", expected, reported)
+			}
+			if out := runner.StdoutBuffer.String(); out != "one\ntwo\nthree\nlines\nfour\nfive\n" {
+				t.Fatalf("unexpected stdout %q", out)
+			}
+		})
+	}
+}
+
+func TestBatchParallelOrdering(t *testing.T) {
+	var running, maxSeen int32
+	var input strings.Builder
+	for i := 0; i < 20; i++ {
+		fmt.Fprintf(&input, "run -sleep %dms %d\n", (20-i)%5, i)
+	}
+
+	runner := cmdy.NewBufferedRunner()
+	batch := Batch{Builder: batchTestBuilder(&running, &maxSeen), Runner: &runner.Runner, Workers: 4}
+	if _, err := batch.Run(context.Background(), strings.NewReader(input.String())); err != nil {
+		t.Fatal(err)
+	}
+
+	var expected strings.Builder
+	for i := 0; i < 20; i++ {
+		fmt.Fprintf(&expected, "%d\n", i)
+	}
+	if out := runner.StdoutBuffer.String(); out != expected.String() {
+		t.Fatalf("unexpected stdout %q", out)
+	}
+	if maxSeen > 4 {
+		t.Fatalf("expected at most 4 commands at once, found %d", maxSeen)
+	}
+}
+
+func TestBatchIncompleteCommand(t *testing.T) {
+	var running, maxSeen int32
+	runner := cmdy.NewBufferedRunner()
+	batch := Batch{Builder: batchTestBuilder(&running, &maxSeen), Runner: &runner.Runner, OnError: BatchContinue}
+	results, err := batch.Run(context.Background(), strings.NewReader("run yep\nrun 'nope\n"))
+	if cmdy.ErrCode(err) != cmdy.ExitUsage {
+		t.Fatalf("expected usage error, found %v", err)
+	}
+	if len(results) != 2 || results[1].Line != 2 {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	if stderr := runner.StderrBuffer.String(); stderr != "line 2: error: incomplete command\n" {
+		t.Fatalf("unexpected stderr %q", stderr)
+	}
+}
+
+func TestBatchCommand(t *testing.T) {
+	var running, maxSeen int32
+	var root cmdy.Builder
+	root = func() cmdy.Command {
+		return cmdy.NewGroup("test", cmdy.Builders{
+			"run":   func() cmdy.Command { return &batchTestCommand{running: &running, maxSeen: &maxSeen} },
+			"batch": BatchCommand(func() cmdy.Command { return root() }),
+		})
+	}
+
+	runner := cmdy.NewBufferedRunner()
+	runner.StdinBuffer.WriteString("run one\nrun -code 2 two\nrun three\n")
+	err := runner.Run(context.Background(), "test", []string{"batch", "-k", "-v", "-"}, root)
+	if cmdy.ErrCode(err) != 2 {
+		t.Fatalf("expected code 2, found %v", err)
+	}
+	if out := runner.StdoutBuffer.String(); out != "one\ntwo\nthree\n" {
+		t.Fatalf("unexpected stdout %q", out)
+	}
+	if stderr := runner.StderrBuffer.String(); stderr != "line 1: exit 0\nline 2: failed two\nline 2: exit 2\nline 3: exit 0\n" {
+		t.Fatalf("unexpected stderr %q", stderr)
+	}
+}
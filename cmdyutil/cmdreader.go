@@ -0,0 +1,76 @@
+package cmdyutil
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/shabbyrobe/cmdy/cmdstr"
+)
+
+// commandReader reads lines and accumulates them into complete commands,
+// using cmdstr.Parse to split them into args. If a line ends part way through
+// a command (for example, inside a quoted string or after a trailing
+// backslash), more lines are read until the command is complete.
+//
+// Blank lines, and lines whose first non-whitespace character is '#', are
+// skipped between commands, as are commands whose args are all empty (for
+// example, a backslash followed by a blank line).
+type commandReader struct {
+	// readLine returns the next line, without the trailing newline.
+	// continuation is true if the line is needed to complete a command.
+	// readLine should return io.EOF when there are no more lines.
+	readLine func(continuation bool) (string, error)
+
+	// Number of lines read so far.
+	line int
+}
+
+// readCommand returns the raw text of the next command and its args.
+// startLine is the 1-based line number at which the command started.
+func (cr *commandReader) readCommand() (raw string, args []string, startLine int, err error) {
+	var buf strings.Builder
+
+	for {
+		line, err := cr.readLine(buf.Len() > 0)
+		if err == io.EOF && buf.Len() > 0 {
+			return buf.String(), nil, startLine, fmt.Errorf("line %d: %w", startLine, cmdstr.ErrIncompleteCommand)
+		} else if err != nil {
+			return "", nil, 0, err
+		}
+		cr.line++
+
+		if buf.Len() == 0 {
+			trimmed := strings.TrimSpace(line)
+			if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+				continue
+			}
+			startLine = cr.line
+		} else {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(line)
+
+		args, err := cmdstr.ParseString(buf.String(), "")
+		if errors.Is(err, cmdstr.ErrIncompleteCommand) {
+			continue
+		} else if err != nil {
+			return buf.String(), nil, startLine, fmt.Errorf("line %d: %w", startLine, err)
+		}
+		if isEmptyCommand(args) {
+			buf.Reset()
+			continue
+		}
+		return buf.String(), args, startLine, nil
+	}
+}
+
+func isEmptyCommand(args []string) bool {
+	for _, arg := range args {
+		if arg != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,350 @@
+package cmdyutil
+
+import (
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/shabbyrobe/cmdy"
+)
+
+// InputFlag controls how NewInputs interprets the names it is given. Flags
+// can be combined using '|'.
+type InputFlag int
+
+const (
+	// InputRecursive walks directories, yielding every file below them in
+	// lexical order. Symlinks to files are followed, as 'cat' would, but
+	// symlinks to directories are not. Without it, directories are an error.
+	InputRecursive InputFlag = 1 << iota
+
+	// InputDecompress decompresses files ending in '.gz' or '.bz2'.
+	InputDecompress
+
+	// InputNoGlob treats names literally instead of expanding glob patterns.
+	InputNoGlob
+)
+
+// InputErrorPolicy controls what Inputs does when an input can not be opened,
+// including a directory that can not be read while walking it with
+// InputRecursive.
+type InputErrorPolicy int
+
+const (
+	// Stop at the first input that can not be opened.
+	InputStopOnError InputErrorPolicy = iota
+
+	// Skip inputs that can not be opened, and report them from Err once all
+	// inputs have been read. Unreadable directories found while walking a
+	// directory are skipped without skipping the rest of the walk.
+	InputContinue
+)
+
+// Input is a single input yielded by Inputs.
+type Input struct {
+	// Name of the file, or '-' for stdin.
+	Name string
+
+	io.Reader
+}
+
+// Inputs iterates over the inputs named by a list of args (for example, from
+// arg.ArgSet.Remaining), with the same semantics as 'cat':
+//
+//   - '-' means the command's stdin.
+//   - If there are no names, stdin is read.
+//   - Glob patterns are expanded with filepath.Glob, unless InputNoGlob is set.
+//     A pattern that matches nothing is an error.
+//   - Directories are walked if InputRecursive is set.
+//   - '.gz' and '.bz2' files are decompressed if InputDecompress is set.
+//
+// Use it like a bufio.Scanner:
+//
+//	inputs := cmdyutil.NewInputs(ctx, cmd.files, cmdyutil.InputDecompress)
+//	defer inputs.Close()
+//	for inputs.Next() {
+//		in := inputs.Input()
+//		if _, err := io.Copy(ctx.Stdout(), in); err != nil {
+//			return fmt.Errorf("%s: %w", in.Name, err)
+//		}
+//	}
+//	if err := inputs.Err(); err != nil {
+//		return err
+//	}
+//
+// Errors opening inputs are *InputError values. What happens when one occurs
+// depends on OnError.
+//
+// NOTE: This API is experimental.
+type Inputs struct {
+	// OnError controls what happens when an input can not be opened. Defaults
+	// to InputStopOnError.
+	OnError InputErrorPolicy
+
+	// OnSkip, if set, is called with each error skipped when OnError is
+	// InputContinue, for example to print a warning.
+	OnSkip func(err *InputError)
+
+	ctx     cmdy.Context
+	flags   InputFlag
+	pending []pendingInput
+	current *Input
+	closer  io.Closer
+	err     error
+	skipped []error
+}
+
+type pendingInput struct {
+	name    string
+	literal bool
+}
+
+// NewInputs creates Inputs for names. No files are opened until Next is
+// called.
+func NewInputs(ctx cmdy.Context, names []string, flags InputFlag) *Inputs {
+	in := &Inputs{ctx: ctx, flags: flags}
+	if len(names) == 0 {
+		names = []string{"-"}
+	}
+	for _, name := range names {
+		in.pending = append(in.pending, pendingInput{name: name, literal: flags&InputNoGlob != 0})
+	}
+	return in
+}
+
+// Next opens the next input, closing the previous one. It returns false when
+// there are no more inputs, or if an error stopped iteration; check Err.
+func (in *Inputs) Next() bool {
+	in.closeCurrent()
+	if in.err != nil {
+		return false
+	}
+
+	for len(in.pending) > 0 {
+		next := in.pending[0]
+		in.pending = in.pending[1:]
+
+		err := in.open(next)
+		if err == nil {
+			if in.current != nil {
+				return true
+			}
+			continue // Expanded into more pending inputs
+		}
+
+		ierr, ok := err.(*InputError)
+		if !ok {
+			ierr = newInputError(next.name, err)
+		}
+		if in.OnError != InputContinue {
+			in.err = ierr
+			return false
+		}
+		in.skip(ierr)
+	}
+	return false
+}
+
+func (in *Inputs) skip(ierr *InputError) {
+	in.skipped = append(in.skipped, ierr)
+	if in.OnSkip != nil {
+		in.OnSkip(ierr)
+	}
+}
+
+// Input returns the input opened by the last call to Next.
+func (in *Inputs) Input() Input {
+	if in.current == nil {
+		return Input{}
+	}
+	return *in.current
+}
+
+// Err returns the error that stopped iteration. If OnError is InputContinue,
+// it returns the skipped errors once all inputs have been read, as an error
+// that implements 'Errors() []error' and 'Unwrap() []error'.
+func (in *Inputs) Err() error {
+	if in.err != nil {
+		return in.err
+	}
+	if len(in.pending) == 0 && len(in.skipped) > 0 {
+		return inputErrors(in.skipped)
+	}
+	return nil
+}
+
+// Close closes the current input. It is safe to call more than once.
+func (in *Inputs) Close() error {
+	return in.closeCurrent()
+}
+
+func (in *Inputs) closeCurrent() (err error) {
+	if in.closer != nil {
+		err = in.closer.Close()
+	}
+	in.current, in.closer = nil, nil
+	return err
+}
+
+func (in *Inputs) open(next pendingInput) error {
+	name := next.name
+	if name == "-" {
+		in.current = &Input{Name: name, Reader: in.ctx.Stdin()}
+		return nil
+	}
+
+	if !next.literal && hasGlobMeta(name) {
+		if _, err := os.Stat(name); err != nil {
+			matches, err := filepath.Glob(name)
+			if err != nil {
+				return err
+			}
+			if len(matches) == 0 {
+				return errNoGlobMatches
+			}
+			in.queue(matches)
+			return nil
+		}
+	}
+
+	info, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		if in.flags&InputRecursive == 0 {
+			return errInputIsDir
+		}
+		var files []string
+		err := filepath.Walk(name, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				if path == name {
+					return err
+				}
+				// Only the unreadable path is skipped, not the rest of the
+				// walk:
+				ierr := newInputError(path, err)
+				if in.OnError != InputContinue {
+					return ierr
+				}
+				in.skip(ierr)
+				return nil
+			}
+
+			mode := info.Mode()
+			if mode&os.ModeSymlink != 0 {
+				// Symlinks to files are read like any other file; if the link
+				// is broken, opening it reports the error. Symlinks to
+				// directories are not walked, as they can form loops:
+				if target, err := os.Stat(path); err == nil && target.IsDir() {
+					return nil
+				}
+				files = append(files, path)
+			} else if mode.IsRegular() {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		in.queue(files)
+		return nil
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	rdr, err := in.decompress(name, f)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	in.current = &Input{Name: name, Reader: rdr}
+	in.closer = &inputCloser{rdr: rdr, file: f}
+	return nil
+}
+
+// inputCloser closes the decompressor reading an input (if it has a Close
+// method, like *gzip.Reader), then the file it reads from.
+type inputCloser struct {
+	rdr  io.Reader
+	file io.Closer
+}
+
+func (c *inputCloser) Close() (err error) {
+	if rc, ok := c.rdr.(io.Closer); ok && rc != c.file {
+		err = rc.Close()
+	}
+	if ferr := c.file.Close(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+func (in *Inputs) queue(names []string) {
+	expanded := make([]pendingInput, 0, len(names)+len(in.pending))
+	for _, name := range names {
+		expanded = append(expanded, pendingInput{name: name, literal: true})
+	}
+	in.pending = append(expanded, in.pending...)
+}
+
+func (in *Inputs) decompress(name string, f io.Reader) (io.Reader, error) {
+	if in.flags&InputDecompress == 0 {
+		return f, nil
+	}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".gz":
+		return gzip.NewReader(f)
+	case ".bz2":
+		return bzip2.NewReader(f), nil
+	}
+	return f, nil
+}
+
+func hasGlobMeta(name string) bool {
+	return strings.ContainsAny(name, `*?[`)
+}
+
+// InputError is an error opening one of the names passed to NewInputs, or a
+// file or directory found below one of them.
+type InputError struct {
+	Name string
+	Err  error
+}
+
+func newInputError(name string, err error) *InputError {
+	// The name is already in the InputError:
+	var perr *os.PathError
+	if errors.As(err, &perr) {
+		err = perr.Err
+	}
+	return &InputError{Name: name, Err: err}
+}
+
+func (e *InputError) Unwrap() error { return e.Err }
+func (e *InputError) Error() string { return fmt.Sprintf("%s: %v", e.Name, e.Err) }
+
+type inputErrors []error
+
+func (e inputErrors) Errors() []error { return e }
+func (e inputErrors) Unwrap() []error { return e }
+
+func (e inputErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	return fmt.Sprintf("%d inputs could not be read, first error: %v", len(e), e[0])
+}
+
+var (
+	errNoGlobMatches = errors.New("no files match pattern")
+	errInputIsDir    = errors.New("is a directory")
+)
@@ -0,0 +1,264 @@
+package cmdyutil
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func inputsTestDir(t *testing.T) (dir string, cleanup func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "cmdyutil-inputs-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte("zipped\n"))
+	zw.Close()
+
+	for name, contents := range map[string][]byte{
+		"a.txt":       []byte("a\n"),
+		"b.txt":       []byte("b\n"),
+		"c.gz":        gz.Bytes(),
+		"sub/d.txt":   []byte("d\n"),
+		"sub/e/f.txt": []byte("f\n"),
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, contents, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func readAllInputs(t *testing.T, inputs *Inputs, dir string) (names []string, contents string) {
+	t.Helper()
+	defer inputs.Close()
+	for inputs.Next() {
+		in := inputs.Input()
+		name := in.Name
+		if rel, err := filepath.Rel(dir, name); err == nil && name != "-" {
+			name = filepath.ToSlash(rel)
+		}
+		names = append(names, name)
+		bts, err := ioutil.ReadAll(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents += string(bts)
+	}
+	return names, contents
+}
+
+func TestInputs(t *testing.T) {
+	dir, cleanup := inputsTestDir(t)
+	defer cleanup()
+
+	ctx := ctxWithStdin([]byte("stdin\n"))
+	inputs := NewInputs(ctx, []string{
+		filepath.Join(dir, "b.txt"),
+		"-",
+		filepath.Join(dir, "*.gz"),
+		filepath.Join(dir, "sub"),
+	}, InputRecursive|InputDecompress)
+
+	names, contents := readAllInputs(t, inputs, dir)
+	if err := inputs.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if exp := []string{"b.txt", "-", "c.gz", "sub/d.txt", "sub/e/f.txt"}; !reflect.DeepEqual(exp, names) {
+		t.Fatal(names)
+	}
+	if exp := "b\nstdin\nzipped\nd\nf\n"; contents != exp {
+		t.Fatalf("%q", contents)
+	}
+}
+
+func TestInputsDefaultStdin(t *testing.T) {
+	ctx := ctxWithStdin([]byte("stdin\n"))
+	inputs := NewInputs(ctx, nil, 0)
+	names, contents := readAllInputs(t, inputs, "")
+	if !reflect.DeepEqual([]string{"-"}, names) || contents != "stdin\n" {
+		t.Fatal(names, contents)
+	}
+}
+
+func TestInputsStopOnError(t *testing.T) {
+	dir, cleanup := inputsTestDir(t)
+	defer cleanup()
+
+	ctx := ctxWithStdin(nil)
+	inputs := NewInputs(ctx, []string{
+		filepath.Join(dir, "a.txt"),
+		filepath.Join(dir, "sub"),
+		filepath.Join(dir, "b.txt"),
+	}, 0)
+
+	names, _ := readAllInputs(t, inputs, dir)
+	if !reflect.DeepEqual([]string{"a.txt"}, names) {
+		t.Fatal(names)
+	}
+	var ierr *InputError
+	if !errors.As(inputs.Err(), &ierr) || ierr.Err != errInputIsDir {
+		t.Fatal(inputs.Err())
+	}
+}
+
+func TestInputsContinue(t *testing.T) {
+	dir, cleanup := inputsTestDir(t)
+	defer cleanup()
+
+	var skipped []string
+	ctx := ctxWithStdin(nil)
+	inputs := NewInputs(ctx, []string{
+		filepath.Join(dir, "nope.txt"),
+		filepath.Join(dir, "a.txt"),
+		filepath.Join(dir, "*.nope"),
+		filepath.Join(dir, "[*.txt"),
+	}, 0)
+	inputs.OnError = InputContinue
+	inputs.OnSkip = func(err *InputError) {
+		rel, _ := filepath.Rel(dir, err.Name)
+		skipped = append(skipped, rel+": "+err.Err.Error())
+	}
+
+	names, _ := readAllInputs(t, inputs, dir)
+	if !reflect.DeepEqual([]string{"a.txt"}, names) {
+		t.Fatal(names)
+	}
+	if exp := []string{
+		"nope.txt: no such file or directory",
+		"*.nope: no files match pattern",
+		"[*.txt: syntax error in pattern",
+	}; !reflect.DeepEqual(exp, skipped) {
+		t.Fatal(skipped)
+	}
+
+	err := inputs.Err()
+	if !errors.Is(err, os.ErrNotExist) || !errors.Is(err, errNoGlobMatches) {
+		t.Fatal(err)
+	}
+}
+
+func TestInputsNoGlob(t *testing.T) {
+	dir, cleanup := inputsTestDir(t)
+	defer cleanup()
+
+	inputs := NewInputs(ctxWithStdin(nil), []string{filepath.Join(dir, "*.txt")}, InputNoGlob)
+	if inputs.Next() {
+		t.Fatal("unexpected input")
+	}
+	if !errors.Is(inputs.Err(), os.ErrNotExist) {
+		t.Fatal(inputs.Err())
+	}
+}
+
+func TestInputsContinueUnreadableDir(t *testing.T) {
+	if os.Geteuid() <= 0 {
+		t.Skip("permissions are not enforced for root, or on this platform")
+	}
+	dir, cleanup := inputsTestDir(t)
+	defer cleanup()
+
+	locked := filepath.Join(dir, "sub", "e")
+	if err := os.Chmod(locked, 0); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(locked, 0777)
+
+	var skipped []string
+	inputs := NewInputs(ctxWithStdin(nil), []string{filepath.Join(dir, "sub")}, InputRecursive)
+	inputs.OnError = InputContinue
+	inputs.OnSkip = func(err *InputError) {
+		rel, _ := filepath.Rel(dir, err.Name)
+		skipped = append(skipped, filepath.ToSlash(rel))
+	}
+
+	names, _ := readAllInputs(t, inputs, dir)
+	if !reflect.DeepEqual([]string{"sub/d.txt"}, names) {
+		t.Fatal(names)
+	}
+	if !reflect.DeepEqual([]string{"sub/e"}, skipped) {
+		t.Fatal(skipped)
+	}
+	if !errors.Is(inputs.Err(), os.ErrPermission) {
+		t.Fatal(inputs.Err())
+	}
+
+	// The whole directory argument fails without InputContinue:
+	inputs = NewInputs(ctxWithStdin(nil), []string{filepath.Join(dir, "sub")}, InputRecursive)
+	names, _ = readAllInputs(t, inputs, dir)
+	var ierr *InputError
+	if len(names) != 0 || !errors.As(inputs.Err(), &ierr) || ierr.Name != locked {
+		t.Fatal(names, inputs.Err())
+	}
+}
+
+func TestInputsRecursiveSymlinks(t *testing.T) {
+	dir, cleanup := inputsTestDir(t)
+	defer cleanup()
+
+	if err := os.Symlink(filepath.Join(dir, "a.txt"), filepath.Join(dir, "sub", "link.txt")); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "sub"), filepath.Join(dir, "sub", "loop")); err != nil {
+		t.Fatal(err)
+	}
+
+	inputs := NewInputs(ctxWithStdin(nil), []string{filepath.Join(dir, "sub")}, InputRecursive)
+	names, contents := readAllInputs(t, inputs, dir)
+	if err := inputs.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if exp := []string{"sub/d.txt", "sub/e/f.txt", "sub/link.txt"}; !reflect.DeepEqual(exp, names) {
+		t.Fatal(names)
+	}
+	if exp := "d\nf\na\n"; contents != exp {
+		t.Fatalf("%q", contents)
+	}
+}
+
+type recordingCloser struct {
+	name   string
+	closed *[]string
+}
+
+func (c *recordingCloser) Read(b []byte) (int, error) { return 0, nil }
+func (c *recordingCloser) Close() error {
+	*c.closed = append(*c.closed, c.name)
+	return nil
+}
+
+func TestInputCloserClosesDecompressor(t *testing.T) {
+	var closed []string
+	file := &recordingCloser{name: "file", closed: &closed}
+
+	// The decompressor is closed before the file it reads from:
+	c := &inputCloser{rdr: &recordingCloser{name: "gzip", closed: &closed}, file: file}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(closed, []string{"gzip", "file"}) {
+		t.Fatal(closed)
+	}
+
+	// Without a decompressor, the file is only closed once:
+	closed = nil
+	c = &inputCloser{rdr: file, file: file}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(closed, []string{"file"}) {
+		t.Fatal(closed)
+	}
+}
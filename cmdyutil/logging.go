@@ -0,0 +1,165 @@
+package cmdyutil
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/shabbyrobe/cmdy"
+)
+
+// LogLevel is the severity of a log message. The values match log/slog's
+// levels.
+type LogLevel int
+
+const (
+	LogDebug LogLevel = -4
+	LogInfo  LogLevel = 0
+	LogWarn  LogLevel = 4
+	LogError LogLevel = 8
+)
+
+// LogFormat is the format of the messages written by a Logger.
+type LogFormat string
+
+const (
+	// LogText writes messages as 'key=value' pairs, e.g.
+	// 'level=INFO msg="file copied" path=foo.txt'.
+	LogText LogFormat = "text"
+
+	// LogJSON writes each message as a JSON object on a single line.
+	LogJSON LogFormat = "json"
+)
+
+// Logger is a leveled, structured logger. Messages take a list of alternating
+// keys and values, in the same way as log/slog:
+//
+//	cmdyutil.Log(ctx).Info("file copied", "path", path, "bytes", n)
+//
+// If the program is built with Go 1.21 or later, messages are written using
+// log/slog, and the underlying *slog.Logger is available from Logger.Slog.
+// Otherwise, a compatible format is written using the log package.
+type Logger struct {
+	level LogLevel
+	impl  logImpl
+}
+
+type logImpl interface {
+	log(level LogLevel, msg string, args []interface{})
+}
+
+// NewLogger returns a Logger that writes messages at or above level to w.
+func NewLogger(w io.Writer, level LogLevel, format LogFormat) *Logger {
+	return &Logger{level: level, impl: newLogImpl(w, level, format)}
+}
+
+func (l *Logger) Level() LogLevel { return l.level }
+
+// Enabled reports whether messages at level are written.
+func (l *Logger) Enabled(level LogLevel) bool { return level >= l.level }
+
+func (l *Logger) Debug(msg string, args ...interface{}) { l.Log(LogDebug, msg, args...) }
+func (l *Logger) Info(msg string, args ...interface{})  { l.Log(LogInfo, msg, args...) }
+func (l *Logger) Warn(msg string, args ...interface{})  { l.Log(LogWarn, msg, args...) }
+func (l *Logger) Error(msg string, args ...interface{}) { l.Log(LogError, msg, args...) }
+
+func (l *Logger) Log(level LogLevel, msg string, args ...interface{}) {
+	if l.Enabled(level) {
+		l.impl.log(level, msg, args)
+	}
+}
+
+// Log returns the Logger configured by GroupLogging for the Group that ctx is
+// running below. If there isn't one, it returns a Logger that writes messages at
+// LogInfo and above to ctx.Stderr() as text.
+func Log(ctx cmdy.Context) *Logger {
+	var logger *Logger
+	if err := cmdy.Resolve(ctx, &logger); err != nil {
+		return NewLogger(ctx.Stderr(), LogInfo, LogText)
+	}
+	return logger
+}
+
+// GroupLogging adds the following flags to a Group, and provides a Logger
+// configured by them to the Group's subcommands, available via Log:
+//
+//	-v                Show debug messages
+//	-q                Only show errors
+//	-log-format=text  Log format, 'text' or 'json'
+//
+// The flags are inherited (see cmdy.FlagSet.MarkInherited), so they can be
+// passed to the Group or to any of its subcommands. If both -v and -q are
+// passed, -v wins. The Logger writes to the Context's Stderr, so
+// cmdy.BufferedRunner captures it in tests.
+//
+// GroupLogging extends the Group's FlagBuilder and Before hook, so it must be
+// passed to cmdy.NewGroup after cmdy.GroupFlags and cmdy.GroupBefore. The
+// Logger is available from the Group's own Before hook.
+//
+// NOTE: This API is experimental.
+func GroupLogging() cmdy.GroupOption {
+	return func(grp *cmdy.Group) {
+		lf := &logFlags{format: LogText}
+
+		flagBuilder := grp.FlagBuilder
+		grp.FlagBuilder = func() *cmdy.FlagSet {
+			var flags *cmdy.FlagSet
+			if flagBuilder != nil {
+				flags = flagBuilder()
+			}
+			if flags == nil {
+				flags = cmdy.NewFlagSet()
+			}
+			lf.configure(flags)
+			return flags
+		}
+
+		before := grp.Before
+		grp.Before = func(ctx cmdy.Context) error {
+			// The Logger is constructed the first time it is resolved, after the
+			// subcommand has parsed any flags it inherited:
+			cmdy.Scope(ctx).Provide(func(ctx cmdy.Context) *Logger {
+				return NewLogger(ctx.Stderr(), lf.level(), lf.format)
+			})
+			if before != nil {
+				return before(ctx)
+			}
+			return nil
+		}
+	}
+}
+
+type logFlags struct {
+	verbose bool
+	quiet   bool
+	format  LogFormat
+}
+
+func (lf *logFlags) configure(flags *cmdy.FlagSet) {
+	flags.BoolVar(&lf.verbose, "v", false, "Show debug messages")
+	flags.BoolVar(&lf.quiet, "q", false, "Only show errors")
+	flags.Var((*logFormatValue)(&lf.format), "log-format", "Log format, 'text' or 'json'")
+	flags.MarkInherited("v", "q", "log-format")
+}
+
+func (lf *logFlags) level() LogLevel {
+	if lf.verbose {
+		return LogDebug
+	} else if lf.quiet {
+		return LogError
+	}
+	return LogInfo
+}
+
+type logFormatValue LogFormat
+
+func (v *logFormatValue) String() string { return string(*v) }
+
+func (v *logFormatValue) Set(s string) error {
+	switch LogFormat(s) {
+	case LogText, LogJSON:
+		*v = logFormatValue(s)
+		return nil
+	default:
+		return fmt.Errorf("unknown log format %q", s)
+	}
+}
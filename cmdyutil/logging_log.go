@@ -0,0 +1,93 @@
+// +build !go1.21
+
+package cmdyutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// stdlogImpl writes messages in the same format as log/slog's TextHandler and
+// JSONHandler, for versions of Go that don't have log/slog.
+type stdlogImpl struct {
+	logger *log.Logger
+	format LogFormat
+}
+
+func newLogImpl(w io.Writer, level LogLevel, format LogFormat) logImpl {
+	return &stdlogImpl{logger: log.New(w, "", 0), format: format}
+}
+
+func (s *stdlogImpl) log(level LogLevel, msg string, args []interface{}) {
+	kvs := []interface{}{"level", levelString(level), "msg", msg}
+	for len(args) > 0 {
+		if key, ok := args[0].(string); ok && len(args) > 1 {
+			kvs, args = append(kvs, key, args[1]), args[2:]
+		} else {
+			kvs, args = append(kvs, "!BADKEY", args[0]), args[1:]
+		}
+	}
+
+	var sb strings.Builder
+	if s.format == LogJSON {
+		sb.WriteByte('{')
+		for i := 0; i < len(kvs); i += 2 {
+			if i > 0 {
+				sb.WriteByte(',')
+			}
+			key, _ := json.Marshal(kvs[i])
+			val, err := json.Marshal(kvs[i+1])
+			if err != nil {
+				val, _ = json.Marshal(fmt.Sprintf("!ERROR:%v", err))
+			}
+			sb.Write(key)
+			sb.WriteByte(':')
+			sb.Write(val)
+		}
+		sb.WriteByte('}')
+
+	} else {
+		for i := 0; i < len(kvs); i += 2 {
+			if i > 0 {
+				sb.WriteByte(' ')
+			}
+			sb.WriteString(quoteLogText(fmt.Sprint(kvs[i])))
+			sb.WriteByte('=')
+			sb.WriteString(quoteLogText(fmt.Sprint(kvs[i+1])))
+		}
+	}
+	s.logger.Output(2, sb.String())
+}
+
+func levelString(level LogLevel) string {
+	name, base := "DEBUG", LogDebug
+	switch {
+	case level >= LogError:
+		name, base = "ERROR", LogError
+	case level >= LogWarn:
+		name, base = "WARN", LogWarn
+	case level >= LogInfo:
+		name, base = "INFO", LogInfo
+	}
+	if level != base {
+		return fmt.Sprintf("%s%+d", name, level-base)
+	}
+	return name
+}
+
+func quoteLogText(s string) string {
+	if s == "" {
+		return `""`
+	}
+	for _, r := range s {
+		if r == '=' || r == '"' || unicode.IsSpace(r) || !unicode.IsPrint(r) {
+			return strconv.Quote(s)
+		}
+	}
+	return s
+}
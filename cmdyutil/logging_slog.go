@@ -0,0 +1,44 @@
+// +build go1.21
+
+package cmdyutil
+
+import (
+	"context"
+	"io"
+	"log/slog"
+)
+
+type slogImpl struct {
+	logger *slog.Logger
+}
+
+func newLogImpl(w io.Writer, level LogLevel, format LogFormat) logImpl {
+	opts := &slog.HandlerOptions{
+		Level: slog.Level(level),
+
+		// Timestamps are noise in the output of a command line tool:
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}
+
+	var handler slog.Handler
+	if format == LogJSON {
+		handler = slog.NewJSONHandler(w, opts)
+	} else {
+		handler = slog.NewTextHandler(w, opts)
+	}
+	return &slogImpl{logger: slog.New(handler)}
+}
+
+func (s *slogImpl) log(level LogLevel, msg string, args []interface{}) {
+	s.logger.Log(context.Background(), slog.Level(level), msg, args...)
+}
+
+// Slog returns the *slog.Logger that the Logger writes to.
+func (l *Logger) Slog() *slog.Logger {
+	return l.impl.(*slogImpl).logger
+}
@@ -0,0 +1,78 @@
+package cmdyutil
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/shabbyrobe/cmdy"
+	"github.com/shabbyrobe/cmdy/arg"
+)
+
+type logTestCommand struct{}
+
+func (cmd *logTestCommand) Help() cmdy.Help                                 { return cmdy.Synopsis("log test") }
+func (cmd *logTestCommand) Configure(flags *cmdy.FlagSet, args *arg.ArgSet) {}
+
+func (cmd *logTestCommand) Run(ctx cmdy.Context) error {
+	log := Log(ctx)
+	log.Debug("debug", "n", 1)
+	log.Info("info", "path", "a b.txt")
+	log.Error("error")
+	return nil
+}
+
+func logTestBuilder() cmdy.Command {
+	return cmdy.NewGroup("log", cmdy.Builders{
+		"cmd": func() cmdy.Command { return &logTestCommand{} },
+	}, GroupLogging())
+}
+
+func TestGroupLogging(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		out  string
+	}{
+		{[]string{"cmd"}, "" +
+			"level=INFO msg=info path=\"a b.txt\"\n" +
+			"level=ERROR msg=error\n"},
+		{[]string{"-v", "cmd"}, "" +
+			"level=DEBUG msg=debug n=1\n" +
+			"level=INFO msg=info path=\"a b.txt\"\n" +
+			"level=ERROR msg=error\n"},
+		{[]string{"cmd", "-q"}, "" +
+			"level=ERROR msg=error\n"},
+		{[]string{"-q", "cmd", "-log-format=json"}, "" +
+			"{\"level\":\"ERROR\",\"msg\":\"error\"}\n"},
+	} {
+		t.Run(strings.Join(tc.args, " "), func(t *testing.T) {
+			runner := cmdy.NewBufferedRunner()
+			if err := runner.Run(context.Background(), "log", tc.args, logTestBuilder); err != nil {
+				t.Fatal(err)
+			}
+			if out := runner.StderrBuffer.String(); out != tc.out {
+				t.Fatalf("expected:\n%s\nfound:\n%s", tc.out, out)
+			}
+		})
+	}
+}
+
+func TestGroupLoggingInvalidFormat(t *testing.T) {
+	runner := cmdy.NewBufferedRunner()
+	err := runner.Run(context.Background(), "log", []string{"cmd", "-log-format=yaml"}, logTestBuilder)
+	if !cmdy.IsUsageError(err) {
+		t.Fatal("expected usage error, found", err)
+	}
+}
+
+func TestLogWithoutGroupLogging(t *testing.T) {
+	runner := cmdy.NewBufferedRunner()
+	bld := func() cmdy.Command { return &logTestCommand{} }
+	if err := runner.Run(context.Background(), "log", nil, bld); err != nil {
+		t.Fatal(err)
+	}
+	expected := "level=INFO msg=info path=\"a b.txt\"\nlevel=ERROR msg=error\n"
+	if out := runner.StderrBuffer.String(); out != expected {
+		t.Fatalf("expected:\n%s\nfound:\n%s", expected, out)
+	}
+}
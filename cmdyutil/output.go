@@ -0,0 +1,294 @@
+package cmdyutil
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/shabbyrobe/cmdy"
+	"github.com/shabbyrobe/cmdy/internal/istty"
+)
+
+type OutputFlag int
+
+const (
+	// OutputForce allows an existing file to be overwritten, and binary output
+	// to be written to a terminal.
+	OutputForce OutputFlag = 1 << iota
+
+	// OutputCompress gzips the output if the file name ends in '.gz'. Other
+	// compressed formats (such as '.bz2' and '.xz') are not supported, so
+	// OpenStdoutOrFile returns an error for those rather than writing
+	// uncompressed data. Output to stdout is never compressed.
+	OutputCompress
+
+	// OutputBinary refuses to write to stdout if it is a terminal, unless
+	// OutputForce is also set.
+	OutputBinary
+)
+
+// Output is a destination opened by OpenStdoutOrFile. Write to it, then call
+// Commit to make the output visible. Close should always be called, usually
+// with defer; if Commit has not been called, Close discards the output:
+//
+//	out, err := cmdyutil.OpenStdoutOrFile(ctx, cmd.output, cmdyutil.OutputCompress)
+//	if err != nil {
+//		return err
+//	}
+//	defer out.Close()
+//
+//	if err := write(ctx, out); err != nil {
+//		return err
+//	}
+//	return out.Commit()
+//
+// NOTE: This API is experimental.
+type Output struct {
+	io.Writer
+
+	// Name of the destination file, or '-' for stdout.
+	Name string
+
+	file     *os.File
+	tmpName  string
+	target   string // Name, with any symlinks resolved
+	compress io.WriteCloser
+
+	// If set, file is the destination itself (for example, a device or a
+	// named pipe) rather than a temporary file.
+	direct bool
+
+	// mu protects done, as Close may be called by a cleanup function while
+	// the command is still running.
+	mu   sync.Mutex
+	done bool
+
+	// If set, Commit does not replace a file created after OpenStdoutOrFile
+	// checked for it.
+	noClobber bool
+}
+
+// OpenStdoutOrFile opens fileName for writing, or returns an Output that writes
+// to ctx.Stdout() if fileName is empty or '-'.
+//
+// Files are written atomically: the output is written to a temporary file in
+// the same directory, which is renamed to fileName by Commit, so readers never
+// see a partially written file, and an interrupted command does not leave one
+// behind. If the command is run by a cmdy.Runner, the temporary file is also
+// removed by a cmdy.OnCleanup function, so it is removed even if the
+// command is abandoned by InterruptRunner.
+//
+// If fileName already exists, an error that wraps os.ErrExist is returned
+// unless OutputForce is set. Commit checks again, so a file created by another
+// process while the output is being written is not replaced either, except on
+// file systems that do not support hard links, where Commit falls back to a
+// plain rename.
+//
+// If fileName is a symlink, the file it points to is replaced, rather than
+// the link. If fileName is not a regular file (for example, '/dev/stdout' or
+// a named pipe), it is opened and written to directly, whether or not
+// OutputForce is set.
+//
+// The new file has the same permissions as the one it replaces. If there
+// isn't one, it is created with mode 0666 (before umask), like os.Create.
+func OpenStdoutOrFile(ctx cmdy.Context, fileName string, flag OutputFlag) (out *Output, err error) {
+	if fileName == "" || fileName == "-" {
+		stdout := ctx.Stdout()
+		if flag&OutputBinary != 0 && flag&OutputForce == 0 && istty.CheckTTY(stdout) == istty.IsTTY {
+			return nil, cmdy.NewError(cmdy.CategoryFailure, errOutputBinaryTTY).
+				WithHint("redirect the output to a file or a pipe")
+		}
+		return &Output{Writer: stdout, Name: "-"}, nil
+	}
+
+	ext := strings.ToLower(filepath.Ext(fileName))
+	if flag&OutputCompress != 0 && unsupportedCompression[ext] {
+		return nil, cmdy.Errorf(cmdy.CategoryCantCreate, "cannot write %s compressed output to %q", ext, fileName).
+			WithHint("use a '.gz' file name, or a name without a compressed extension")
+	}
+
+	// The file a symlink points to is replaced, not the link itself:
+	target := fileName
+	if resolved, err := filepath.EvalSymlinks(fileName); err == nil {
+		target = resolved
+	}
+
+	var replace os.FileInfo
+	if info, err := os.Stat(target); err == nil {
+		if !info.Mode().IsRegular() {
+			return openDirectOutput(fileName, target, ext, flag)
+		}
+		if flag&OutputForce == 0 {
+			return nil, errOutputExists(fileName)
+		}
+		replace = info
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	dir, base := filepath.Split(target)
+	f, err := createTempFile(dir, "."+base+".tmp-")
+	if err != nil {
+		return nil, err
+	}
+	if replace != nil {
+		if err := f.Chmod(replace.Mode().Perm()); err != nil {
+			f.Close()
+			os.Remove(f.Name())
+			return nil, err
+		}
+	}
+
+	out = &Output{
+		Writer:    f,
+		Name:      fileName,
+		file:      f,
+		tmpName:   f.Name(),
+		target:    target,
+		noClobber: flag&OutputForce == 0,
+	}
+	out.setCompress(ext, flag)
+
+	// Close does nothing once the Output has been committed or closed, so
+	// the cleanup function only removes output that was abandoned:
+	if cleanups := cmdy.CleanupsFromContext(ctx); cleanups != nil {
+		cleanups.Add(func(ctx context.Context) error {
+			return out.Close()
+		})
+	}
+
+	return out, nil
+}
+
+// openDirectOutput opens target, which exists but is not a regular file, for
+// writing without a temporary file. Devices and named pipes can't be replaced
+// atomically, and writing to them doesn't clobber anything.
+func openDirectOutput(fileName, target, ext string, flag OutputFlag) (*Output, error) {
+	f, err := os.OpenFile(target, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	out := &Output{Writer: f, Name: fileName, file: f, direct: true}
+	out.setCompress(ext, flag)
+	return out, nil
+}
+
+func (o *Output) setCompress(ext string, flag OutputFlag) {
+	if flag&OutputCompress != 0 && ext == ".gz" {
+		o.compress = gzip.NewWriter(o.file)
+		o.Writer = o.compress
+	}
+}
+
+// Commit flushes the output and renames the temporary file to Name. After
+// Commit, Close does nothing. Commit does nothing for stdout. If Name is not a
+// regular file, Commit flushes and closes it.
+func (o *Output) Commit() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.done || o.file == nil {
+		o.done = true
+		return nil
+	}
+	o.done = true
+
+	if o.compress != nil {
+		if err := o.compress.Close(); err != nil {
+			o.discard()
+			return err
+		}
+	}
+	if o.direct {
+		return o.file.Close()
+	}
+	if err := o.file.Sync(); err != nil {
+		o.discard()
+		return err
+	}
+	if err := o.file.Close(); err != nil {
+		os.Remove(o.tmpName)
+		return err
+	}
+
+	if o.noClobber {
+		// Unlike Rename, Link fails if the destination exists:
+		err := os.Link(o.tmpName, o.target)
+		if err == nil || os.IsExist(err) {
+			os.Remove(o.tmpName)
+			if err != nil {
+				return errOutputExists(o.Name)
+			}
+			return nil
+		}
+	}
+
+	if err := os.Rename(o.tmpName, o.target); err != nil {
+		os.Remove(o.tmpName)
+		return err
+	}
+	return nil
+}
+
+// Close discards the output if Commit has not been called. It is safe to call
+// more than once. If Name is not a regular file, anything already written to
+// it can't be discarded, so Close only closes it.
+func (o *Output) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.done || o.file == nil {
+		o.done = true
+		return nil
+	}
+	o.done = true
+	return o.discard()
+}
+
+func (o *Output) discard() error {
+	err := o.file.Close()
+	if o.direct {
+		return err
+	}
+	if rerr := os.Remove(o.tmpName); rerr != nil && !os.IsNotExist(rerr) && err == nil {
+		err = rerr
+	}
+	return err
+}
+
+// createTempFile is like ioutil.TempFile, but creates the file with mode 0666
+// (before umask) rather than 0600, so that the output has the same permissions
+// as a file created by os.Create.
+func createTempFile(dir, prefix string) (*os.File, error) {
+	var suffix [6]byte
+	for i := 0; i < 100; i++ {
+		if _, err := rand.Read(suffix[:]); err != nil {
+			return nil, err
+		}
+		name := filepath.Join(dir, prefix+hex.EncodeToString(suffix[:]))
+		f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+		if os.IsExist(err) {
+			continue
+		}
+		return f, err
+	}
+	return nil, &os.PathError{Op: "createtemp", Path: filepath.Join(dir, prefix+"*"), Err: os.ErrExist}
+}
+
+func errOutputExists(fileName string) error {
+	return cmdy.NewError(cmdy.CategoryCantCreate,
+		&os.PathError{Op: "create", Path: fileName, Err: os.ErrExist})
+}
+
+// Extensions of compressed formats that OutputCompress can't write:
+var unsupportedCompression = map[string]bool{
+	".bz2": true, ".xz": true, ".lzma": true, ".lz": true, ".lz4": true,
+	".zst": true, ".br": true, ".z": true, ".sz": true,
+}
+
+var errOutputBinaryTTY = errors.New("refusing to write binary output to a terminal")
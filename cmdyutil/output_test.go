@@ -0,0 +1,352 @@
+package cmdyutil
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/shabbyrobe/cmdy"
+)
+
+func ctxWithStdout(stdout *bytes.Buffer) *testContext {
+	return &testContext{Context: context.Background(), stdout: stdout}
+}
+
+func mustOpenStdoutOrFile(t *testing.T, ctx cmdy.Context, fname string, flag OutputFlag) *Output {
+	t.Helper()
+	out, err := OpenStdoutOrFile(ctx, fname, flag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestOpenStdoutOrFileStdout(t *testing.T) {
+	for _, fname := range []string{"", "-"} {
+		var stdout bytes.Buffer
+		out := mustOpenStdoutOrFile(t, ctxWithStdout(&stdout), fname, 0)
+		out.Write([]byte("data"))
+		if err := out.Commit(); err != nil {
+			t.Fatal(err)
+		}
+		if err := out.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if stdout.String() != "data" {
+			t.Fatal(stdout.String())
+		}
+	}
+}
+
+func TestOpenStdoutOrFileAtomic(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var stdout bytes.Buffer
+	fname := filepath.Join(dir, "out.txt")
+	out := mustOpenStdoutOrFile(t, ctxWithStdout(&stdout), fname, 0)
+	defer out.Close()
+	out.Write([]byte("data"))
+
+	if _, err := os.Stat(fname); !os.IsNotExist(err) {
+		t.Fatal("file visible before commit", err)
+	}
+	if err := out.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "data" {
+		t.Fatal(string(b))
+	}
+	if stdout.Len() != 0 {
+		t.Fatal(stdout.String())
+	}
+	assertDirEntries(t, dir, 1)
+}
+
+func TestOpenStdoutOrFileCloseDiscards(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fname := filepath.Join(dir, "out.txt")
+	out := mustOpenStdoutOrFile(t, ctxWithStdout(&bytes.Buffer{}), fname, 0)
+	out.Write([]byte("partial"))
+	if err := out.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := out.Close(); err != nil {
+		t.Fatal(err)
+	}
+	assertDirEntries(t, dir, 0)
+}
+
+func TestOpenStdoutOrFileExisting(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fname := filepath.Join(dir, "out.txt")
+	if err := ioutil.WriteFile(fname, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := ctxWithStdout(&bytes.Buffer{})
+	_, err = OpenStdoutOrFile(ctx, fname, 0)
+	if !errors.Is(err, os.ErrExist) {
+		t.Fatal(err)
+	}
+	if cmdy.ErrCode(err) != cmdy.ExitCantCreate {
+		t.Fatal(cmdy.ErrCode(err))
+	}
+
+	out := mustOpenStdoutOrFile(t, ctx, fname, OutputForce)
+	defer out.Close()
+	out.Write([]byte("new"))
+	if err := out.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadFile(fname)
+	if string(b) != "new" {
+		t.Fatal(string(b))
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Fatal(info.Mode())
+	}
+}
+
+func TestOpenStdoutOrFileCompress(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fname := filepath.Join(dir, "out.txt.gz")
+	out := mustOpenStdoutOrFile(t, ctxWithStdout(&bytes.Buffer{}), fname, OutputCompress)
+	defer out.Close()
+	out.Write([]byte("data"))
+	if err := out.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertFileContents(t, gz, []byte("data"))
+}
+
+func TestOpenStdoutOrFileCleanup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var cleanups cmdy.Cleanups
+	ctx := ctxWithStdout(&bytes.Buffer{})
+	ctx.Context = cmdy.WithCleanups(context.Background(), &cleanups)
+
+	out := mustOpenStdoutOrFile(t, ctx, filepath.Join(dir, "out.txt"), 0)
+	out.Write([]byte("partial"))
+
+	// Simulate a command abandoned after an interrupt, which never closes its
+	// Output:
+	if err := cleanups.Run(); err != nil {
+		t.Fatal(err)
+	}
+	assertDirEntries(t, dir, 0)
+}
+
+func assertDirEntries(t *testing.T, dir string, n int) {
+	t.Helper()
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != n {
+		t.Fatal("expected", n, "entries, found", len(infos))
+	}
+}
+
+func TestOpenStdoutOrFileMode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// New files should respect the umask, like os.Create:
+	ref, err := os.Create(filepath.Join(dir, "ref.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref.Close()
+	refInfo, err := os.Stat(ref.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fname := filepath.Join(dir, "out.txt")
+	out := mustOpenStdoutOrFile(t, ctxWithStdout(&bytes.Buffer{}), fname, 0)
+	defer out.Close()
+	if err := out.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode() != refInfo.Mode() {
+		t.Fatal("expected mode", refInfo.Mode(), "found", info.Mode())
+	}
+}
+
+func TestOpenStdoutOrFileUnsupportedCompression(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ctx := ctxWithStdout(&bytes.Buffer{})
+	_, err = OpenStdoutOrFile(ctx, filepath.Join(dir, "out.bz2"), OutputCompress)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	assertDirEntries(t, dir, 0)
+
+	// Without OutputCompress, the name is just a name:
+	out := mustOpenStdoutOrFile(t, ctx, filepath.Join(dir, "out.bz2"), 0)
+	out.Close()
+}
+
+func TestOpenStdoutOrFileCreatedWhileWriting(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fname := filepath.Join(dir, "out.txt")
+	out := mustOpenStdoutOrFile(t, ctxWithStdout(&bytes.Buffer{}), fname, 0)
+	defer out.Close()
+	out.Write([]byte("new"))
+
+	if err := ioutil.WriteFile(fname, []byte("other"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := out.Commit(); !errors.Is(err, os.ErrExist) {
+		t.Fatal("expected os.ErrExist, found", err)
+	}
+	b, _ := ioutil.ReadFile(fname)
+	if string(b) != "other" {
+		t.Fatal(string(b))
+	}
+	assertDirEntries(t, dir, 1)
+}
+
+func TestOpenStdoutOrFileDevice(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip()
+	}
+
+	// Devices are written to directly, without OutputForce:
+	out := mustOpenStdoutOrFile(t, ctxWithStdout(&bytes.Buffer{}), os.DevNull, 0)
+	defer out.Close()
+	if _, err := out.Write([]byte("data")); err != nil {
+		t.Fatal(err)
+	}
+	if err := out.Commit(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpenStdoutOrFileSymlink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	target := filepath.Join(dir, "target.txt")
+	link := filepath.Join(dir, "link.txt")
+	if err := ioutil.WriteFile(target, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, link); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+
+	out := mustOpenStdoutOrFile(t, ctxWithStdout(&bytes.Buffer{}), link, OutputForce)
+	defer out.Close()
+	out.Write([]byte("new"))
+	if err := out.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The file the link points to is replaced, and the link is left alone:
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Fatal("symlink replaced", err)
+	}
+	b, _ := ioutil.ReadFile(target)
+	if string(b) != "new" {
+		t.Fatal(string(b))
+	}
+	assertDirEntries(t, dir, 2)
+}
+
+func TestOpenStdoutOrFileCleanupAfterCommit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var cleanups cmdy.Cleanups
+	ctx := ctxWithStdout(&bytes.Buffer{})
+	ctx.Context = cmdy.WithCleanups(context.Background(), &cleanups)
+
+	fname := filepath.Join(dir, "out.txt")
+	out := mustOpenStdoutOrFile(t, ctx, fname, 0)
+	out.Write([]byte("data"))
+	if err := out.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The cleanup function does nothing once the Output is committed:
+	if err := cleanups.Run(); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadFile(fname)
+	if string(b) != "data" {
+		t.Fatal(string(b))
+	}
+}
@@ -0,0 +1,527 @@
+package cmdyutil
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/shabbyrobe/cmdy"
+	"github.com/shabbyrobe/cmdy/arg"
+	"github.com/shabbyrobe/cmdy/cmdstr"
+	"github.com/shabbyrobe/cmdy/internal/istty"
+)
+
+const (
+	DefaultShellPrompt             = "> "
+	DefaultShellContinuationPrompt = "... "
+	DefaultShellHistorySize        = 1000
+)
+
+// LineReader reads lines of input for a Shell. ReadLine should display the
+// prompt, if appropriate, and return the line without the trailing newline.
+// It should return io.EOF when there is no more input.
+//
+// The default LineReader reads from the Runner's Stdin and only displays the
+// prompt if Stdin is a terminal. It does no line editing, so it does not
+// support tab completion or recalling history with the arrow keys; to offer
+// those, supply a LineReader that wraps a line editing library. If the
+// LineReader implements 'SetCompleter(func(line string) []string)', it is
+// passed Shell.Complete, which completes commands and flags from the command
+// tree. If it implements 'AddHistory(line string)', it is passed each command
+// that is added to the Shell's history, including those loaded from
+// HistoryFile.
+type LineReader interface {
+	ReadLine(prompt string) (string, error)
+}
+
+// Shell reads commands from a LineReader, one at a time, and runs them against
+// a Builder using a cmdy.Runner. It allows you to offer an interactive mode for
+// an existing command tree without writing a second dispatcher.
+//
+// Commands are split into args using the same rules as cmdstr.Parse. If a line
+// ends part way through a command (for example, inside a quoted string or after
+// a trailing backslash), more lines are read using ContinuationPrompt until the
+// command is complete. Blank lines and lines starting with '#' are ignored.
+//
+// The following builtin commands are handled by the Shell itself, and take
+// precedence over any commands of the same name in the tree:
+//
+//	exit [code]   Stop reading commands. If code is given, Run returns a
+//	              cmdy.QuietExit with that code.
+//	quit          Same as exit.
+//	history       Print the command history.
+//
+// Errors returned by commands are printed to the Runner's Stderr using
+// cmdy.FormatError and do not stop the Shell.
+//
+// If an os.Interrupt is received while a command is running, the command's
+// context is cancelled. The Shell waits for the command to return, then
+// continues with the next command. Interrupts received while waiting for input
+// are ignored.
+//
+// Tab completion requires a LineReader that supports it; see LineReader.
+//
+// NOTE: This API is experimental.
+type Shell struct {
+	Builder cmdy.Builder
+
+	// Runner used to run each command. If nil, cmdy.DefaultRunner() is used.
+	Runner *cmdy.Runner
+
+	// Name passed to Runner.Run for each command. Defaults to cmdy.ProgName().
+	Name string
+
+	// Prompts used by the default LineReader, or passed to LineReader.
+	// If empty, DefaultShellPrompt and DefaultShellContinuationPrompt are used.
+	Prompt             string
+	ContinuationPrompt string
+
+	// LineReader to read commands from. If nil, commands are read from the
+	// Runner's Stdin.
+	LineReader LineReader
+
+	// If set, history is loaded from this file when Run starts, and each
+	// command is appended to it after it is read. The file contains one command
+	// per line; commands which span multiple lines are Go-quoted.
+	HistoryFile string
+
+	// Maximum number of history entries to keep. If zero,
+	// DefaultShellHistorySize is used.
+	HistorySize int
+
+	history []string
+}
+
+// ShellCommand returns a Builder for a command that runs a Shell against root.
+// The command uses the Runner it is run with, and the name of the first
+// command on the stack as the Shell's Name. Mount it in your root Group to
+// provide a 'tool shell' command:
+//
+//	var root cmdy.Builder
+//	root = func() cmdy.Command {
+//		return cmdy.NewGroup("My tool", cmdy.Builders{
+//			"shell": cmdyutil.ShellCommand(func() cmdy.Command { return root() }),
+//		})
+//	}
+//
+// The Shell started by the command does not allow 'shell' to be run again.
+// It uses the default LineReader, so it does not offer tab completion; use a
+// Shell with your own LineReader for that.
+func ShellCommand(root cmdy.Builder) cmdy.Builder {
+	return func() cmdy.Command {
+		return &shellCommand{root: root}
+	}
+}
+
+type shellCommand struct {
+	root        cmdy.Builder
+	historyFile string
+}
+
+func (sc *shellCommand) Help() cmdy.Help { return cmdy.Synopsis("Run commands interactively") }
+
+func (sc *shellCommand) Configure(flags *cmdy.FlagSet, args *arg.ArgSet) {
+	flags.StringVar(&sc.historyFile, "history", "", "Load and save command history in this file")
+}
+
+func (sc *shellCommand) Run(ctx cmdy.Context) error {
+	stack := ctx.Stack()
+	current := stack[len(stack)-1].Name
+	shell := Shell{
+		Builder:     noNestedShell(sc.root, current),
+		Runner:      ctx.Runner(),
+		Name:        stack[0].Name,
+		HistoryFile: sc.historyFile,
+	}
+	return shell.Run(ctx)
+}
+
+// noNestedShell prevents the shell command from being run again from inside
+// the Shell by rewriting the root Group.
+func noNestedShell(root cmdy.Builder, name string) cmdy.Builder {
+	return func() cmdy.Command {
+		cmd := root()
+		grp, ok := cmd.(*cmdy.Group)
+		if !ok {
+			return cmd
+		}
+		rewriter := grp.Rewriter
+		grp.Rewriter = func(grp *cmdy.Group, state cmdy.GroupRunState) *cmdy.GroupRunState {
+			if rewriter != nil {
+				if out := rewriter(grp, state); out != nil {
+					state = *out
+				}
+			}
+			if state.Name == name {
+				state.Builder = nil
+			}
+			return &state
+		}
+		return grp
+	}
+}
+
+// History returns the Shell's command history, oldest first.
+func (s *Shell) History() []string {
+	return append([]string(nil), s.history...)
+}
+
+// Run reads and runs commands until the LineReader returns io.EOF, the exit
+// builtin is used, or ctx is cancelled. If ctx is cancelled while the Shell is
+// waiting for input, Run returns ctx.Err() without waiting for the LineReader.
+//
+// While the Shell is running, os.Interrupt (Ctrl-C) cancels the command that
+// is currently running, rather than stopping the program. If the Shell is run
+// by an InterruptRunner (for example, using ShellCommand with
+// InterruptibleRun), the Shell takes over os.Interrupt from the
+// InterruptRunner; its other signals (such as SIGTERM) still stop the Shell.
+func (s *Shell) Run(ctx context.Context) error {
+	sig := make(chan os.Signal, 1)
+	forward := func(received os.Signal) bool {
+		if received != os.Interrupt {
+			return false
+		}
+		select {
+		case sig <- received:
+		default:
+		}
+		return true
+	}
+	if stop, ok := interceptSignals(ctx, forward); ok {
+		defer stop()
+	} else {
+		signal.Notify(sig, os.Interrupt)
+		defer signal.Stop(sig)
+	}
+	return s.run(ctx, sig)
+}
+
+func (s *Shell) run(ctx context.Context, sig <-chan os.Signal) error {
+	runner := s.Runner
+	if runner == nil {
+		runner = cmdy.DefaultRunner()
+	}
+	name := s.Name
+	if name == "" {
+		name = cmdy.ProgName()
+	}
+
+	if err := s.loadHistory(); err != nil {
+		return err
+	}
+
+	lr := s.LineReader
+	if lr == nil {
+		lr = newStreamLineReader(runner.Stdin, runner.Stdout)
+	}
+	if c, ok := lr.(interface {
+		SetCompleter(func(line string) []string)
+	}); ok {
+		c.SetCompleter(s.Complete)
+	}
+	if h, ok := lr.(interface{ AddHistory(line string) }); ok {
+		for _, line := range s.history {
+			h.AddHistory(line)
+		}
+	}
+
+	prompt, contPrompt := s.Prompt, s.ContinuationPrompt
+	if prompt == "" {
+		prompt = DefaultShellPrompt
+	}
+	if contPrompt == "" {
+		contPrompt = DefaultShellContinuationPrompt
+	}
+
+	cr := commandReader{readLine: func(continuation bool) (string, error) {
+		if continuation {
+			return readLineContext(ctx, lr, contPrompt)
+		}
+		return readLineContext(ctx, lr, prompt)
+	}}
+
+	// Interrupts are only forwarded to the command that is currently running:
+	var mu sync.Mutex
+	var cancelCurrent context.CancelFunc
+	stopSignals := make(chan struct{})
+	defer close(stopSignals)
+	go func() {
+		for {
+			select {
+			case <-sig:
+				mu.Lock()
+				if cancelCurrent != nil {
+					cancelCurrent()
+				}
+				mu.Unlock()
+			case <-stopSignals:
+				return
+			}
+		}
+	}()
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		raw, args, _, err := cr.readCommand()
+		if err == io.EOF {
+			return nil
+		} else if errors.Is(err, cmdstr.ErrIncompleteCommand) {
+			fmt.Fprintln(runner.Stderr, err)
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		if err := s.addHistory(strings.TrimSpace(raw), lr); err != nil {
+			fmt.Fprintln(runner.Stderr, err)
+		}
+
+		switch args[0] {
+		case "exit", "quit":
+			if len(args) > 1 {
+				code, err := strconv.Atoi(args[1])
+				if err != nil {
+					fmt.Fprintf(runner.Stderr, "%s: invalid exit code %q\n", args[0], args[1])
+					continue
+				}
+				return cmdy.QuietExit(code)
+			}
+			return nil
+
+		case "history":
+			for i, line := range s.history {
+				fmt.Fprintf(runner.Stdout, "%5d  %s\n", i+1, line)
+			}
+			continue
+		}
+
+		cmdCtx, cancel := context.WithCancel(ctx)
+		mu.Lock()
+		cancelCurrent = cancel
+		mu.Unlock()
+
+		err = runner.Run(cmdCtx, name, args, s.Builder)
+
+		mu.Lock()
+		cancelCurrent = nil
+		mu.Unlock()
+		cancel()
+
+		if err != nil {
+			if msg, _ := runner.FormatError(err); msg != "" {
+				fmt.Fprintln(runner.Stderr, msg)
+			}
+		}
+	}
+}
+
+// Complete returns the candidates for the last word in line, which may be the
+// name of a subcommand or a flag, in sorted order. Words before the last one
+// are used to find the command to complete for by walking down through any
+// cmdy.Groups.
+func (s *Shell) Complete(line string) []string {
+	words, err := cmdstr.ParseString(line, "")
+	if err != nil {
+		return nil
+	}
+
+	var prefix string
+	if len(words) > 0 && !strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\t") {
+		prefix, words = words[len(words)-1], words[:len(words)-1]
+	}
+
+	cmd := s.Builder()
+	for _, word := range words {
+		if strings.HasPrefix(word, "-") {
+			continue
+		}
+		grp, ok := cmd.(*cmdy.Group)
+		if !ok {
+			break
+		}
+		bld, _, err := grp.Builder(word)
+		if err != nil || bld == nil {
+			return nil
+		}
+		cmd = bld()
+	}
+
+	var candidates []string
+	if strings.HasPrefix(prefix, "-") {
+		flags := cmdy.NewFlagSet()
+		if fcmd, ok := cmd.(interface{ Flags() *cmdy.FlagSet }); ok {
+			if fs := fcmd.Flags(); fs != nil {
+				flags = fs
+			}
+		}
+		cmd.Configure(flags, arg.NewArgSet())
+		dashes := "-"
+		if strings.HasPrefix(prefix, "--") {
+			dashes = "--"
+		}
+		flags.VisitAll(func(fl *flag.Flag) {
+			if name := dashes + fl.Name; strings.HasPrefix(name, prefix) {
+				candidates = append(candidates, name)
+			}
+		})
+
+	} else if grp, ok := cmd.(*cmdy.Group); ok {
+		for name := range grp.Builders {
+			if strings.HasPrefix(name, prefix) {
+				candidates = append(candidates, name)
+			}
+		}
+		if len(words) == 0 {
+			for _, name := range []string{"exit", "history", "quit"} {
+				if strings.HasPrefix(name, prefix) {
+					candidates = append(candidates, name)
+				}
+			}
+		}
+	}
+
+	sort.Strings(candidates)
+	return candidates
+}
+
+func (s *Shell) historySize() int {
+	if s.HistorySize <= 0 {
+		return DefaultShellHistorySize
+	}
+	return s.HistorySize
+}
+
+func (s *Shell) loadHistory() error {
+	if s.HistoryFile == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(s.HistoryFile)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var history []string
+	for _, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, `"`) {
+			if unquoted, err := strconv.Unquote(line); err == nil {
+				line = unquoted
+			}
+		}
+		history = append(history, line)
+	}
+
+	size := s.historySize()
+	if len(history) <= size {
+		s.history = history
+		return nil
+	}
+
+	// Trim the file so it doesn't grow forever:
+	s.history = history[len(history)-size:]
+	var out strings.Builder
+	for _, line := range s.history {
+		out.WriteString(encodeHistory(line))
+		out.WriteByte('\n')
+	}
+	return ioutil.WriteFile(s.HistoryFile, []byte(out.String()), 0600)
+}
+
+func (s *Shell) addHistory(line string, lr LineReader) error {
+	if n := len(s.history); n > 0 && s.history[n-1] == line {
+		return nil
+	}
+	s.history = append(s.history, line)
+	if size := s.historySize(); len(s.history) > size {
+		s.history = s.history[len(s.history)-size:]
+	}
+	if h, ok := lr.(interface{ AddHistory(line string) }); ok {
+		h.AddHistory(line)
+	}
+
+	if s.HistoryFile == "" {
+		return nil
+	}
+	f, err := os.OpenFile(s.HistoryFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(encodeHistory(line) + "\n")
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func encodeHistory(line string) string {
+	if strings.Contains(line, "\n") || strings.HasPrefix(line, `"`) {
+		return strconv.Quote(line)
+	}
+	return line
+}
+
+// readLineContext calls lr.ReadLine, but returns ctx.Err() if ctx is done
+// first. The ReadLine call is abandoned, and the line it returns, if any, is
+// discarded.
+func readLineContext(ctx context.Context, lr LineReader, prompt string) (string, error) {
+	type result struct {
+		line string
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		line, err := lr.ReadLine(prompt)
+		done <- result{line, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.line, r.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+type streamLineReader struct {
+	scanner    *bufio.Scanner
+	out        io.Writer
+	showPrompt bool
+}
+
+func newStreamLineReader(in io.Reader, out io.Writer) *streamLineReader {
+	return &streamLineReader{
+		scanner:    bufio.NewScanner(in),
+		out:        out,
+		showPrompt: istty.CheckTTY(in) == istty.IsTTY,
+	}
+}
+
+func (sr *streamLineReader) ReadLine(prompt string) (string, error) {
+	if sr.showPrompt {
+		fmt.Fprint(sr.out, prompt)
+	}
+	if !sr.scanner.Scan() {
+		if err := sr.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return strings.TrimRight(sr.scanner.Text(), "\r"), nil
+}
@@ -0,0 +1,312 @@
+package cmdyutil
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/shabbyrobe/cmdy"
+	"github.com/shabbyrobe/cmdy/arg"
+)
+
+type shellEchoCommand struct {
+	upper bool
+	args  []string
+}
+
+func (cmd *shellEchoCommand) Help() cmdy.Help { return cmdy.Synopsis("echo") }
+
+func (cmd *shellEchoCommand) Configure(flags *cmdy.FlagSet, args *arg.ArgSet) {
+	flags.BoolVar(&cmd.upper, "upper", false, "uppercase")
+	args.Remaining(&cmd.args, "args", arg.AnyLen, "args")
+}
+
+func (cmd *shellEchoCommand) Run(ctx cmdy.Context) error {
+	out := strings.Join(cmd.args, "|")
+	if cmd.upper {
+		out = strings.ToUpper(out)
+	}
+	fmt.Fprintln(ctx.Stdout(), out)
+	return nil
+}
+
+type shellWaitCommand struct {
+	started chan struct{}
+}
+
+func (cmd *shellWaitCommand) Help() cmdy.Help                                 { return cmdy.Synopsis("wait") }
+func (cmd *shellWaitCommand) Configure(flags *cmdy.FlagSet, args *arg.ArgSet) {}
+
+func (cmd *shellWaitCommand) Run(ctx cmdy.Context) error {
+	close(cmd.started)
+	<-ctx.Done()
+	fmt.Fprintln(ctx.Stdout(), "cancelled")
+	return nil
+}
+
+func shellTestBuilder(started chan struct{}) cmdy.Builder {
+	var root cmdy.Builder
+	root = func() cmdy.Command {
+		return cmdy.NewGroup("test", cmdy.Builders{
+			"echo":  func() cmdy.Command { return &shellEchoCommand{} },
+			"wait":  func() cmdy.Command { return &shellWaitCommand{started: started} },
+			"shell": ShellCommand(func() cmdy.Command { return root() }),
+			"sub": func() cmdy.Command {
+				return cmdy.NewGroup("sub", cmdy.Builders{
+					"echo": func() cmdy.Command { return &shellEchoCommand{} },
+				})
+			},
+		})
+	}
+	return root
+}
+
+func TestShell(t *testing.T) {
+	runner := cmdy.NewBufferedRunner()
+	runner.StdinBuffer.WriteString(strings.Join([]string{
+		"# comment",
+		"echo foo 'bar baz'",
+		"",
+		"  sub echo -upper yep",
+		`echo "multi`,
+		`line" \`,
+		"continued",
+		"nope",
+		"echo after error",
+		"exit",
+		"echo not reached",
+	}, "\n"))
+
+	shell := Shell{Builder: shellTestBuilder(nil), Runner: &runner.Runner, Name: "test"}
+	if err := shell.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "foo|bar baz\nYEP\nmulti\nline|continued\nafter|error\n"
+	if out := runner.StdoutBuffer.String(); out != expected {
+		t.Fatalf("expected stdout %q, found %q", expected, out)
+	}
+	if stderr := runner.StderrBuffer.String(); !strings.Contains(stderr, `error: unknown command "nope"`) {
+		t.Fatalf("expected unknown command error, found %q", stderr)
+	}
+
+	expectedHistory := []string{"echo foo 'bar baz'", "sub echo -upper yep", "echo \"multi\nline\" \\\ncontinued", "nope", "echo after error", "exit"}
+	if h := shell.History(); !reflect.DeepEqual(expectedHistory, h) {
+		t.Fatalf("expected history %q, found %q", expectedHistory, h)
+	}
+}
+
+func TestShellSkipsEmptyCommand(t *testing.T) {
+	runner := cmdy.NewBufferedRunner()
+	runner.StdinBuffer.WriteString("\\\n\n'' \"\"\necho yep\n")
+	shell := Shell{Builder: shellTestBuilder(nil), Runner: &runner.Runner}
+	if err := shell.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if out := runner.StdoutBuffer.String(); out != "yep\n" {
+		t.Fatalf("unexpected stdout %q", out)
+	}
+	if stderr := runner.StderrBuffer.String(); stderr != "" {
+		t.Fatalf("unexpected stderr %q", stderr)
+	}
+}
+
+func TestShellExitCode(t *testing.T) {
+	runner := cmdy.NewBufferedRunner()
+	runner.StdinBuffer.WriteString("exit 3\n")
+	shell := Shell{Builder: shellTestBuilder(nil), Runner: &runner.Runner}
+	if err := shell.Run(context.Background()); cmdy.ErrCode(err) != 3 {
+		t.Fatalf("expected code 3, found %v", err)
+	}
+}
+
+func TestShellIncompleteAtEOF(t *testing.T) {
+	runner := cmdy.NewBufferedRunner()
+	runner.StdinBuffer.WriteString("echo yep\necho 'nope\n")
+	shell := Shell{Builder: shellTestBuilder(nil), Runner: &runner.Runner}
+	if err := shell.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if out := runner.StdoutBuffer.String(); out != "yep\n" {
+		t.Fatalf("unexpected stdout %q", out)
+	}
+	if stderr := runner.StderrBuffer.String(); !strings.Contains(stderr, "line 2: incomplete command") {
+		t.Fatalf("unexpected stderr %q", stderr)
+	}
+}
+
+func TestShellNoNestedShell(t *testing.T) {
+	runner := cmdy.NewBufferedRunner()
+	runner.StdinBuffer.WriteString("shell\necho yep\n")
+	err := runner.Run(context.Background(), "test", []string{"shell"}, shellTestBuilder(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stderr := runner.StderrBuffer.String(); !strings.Contains(stderr, `unknown command "shell"`) {
+		t.Fatalf("unexpected stderr %q", stderr)
+	}
+	if out := runner.StdoutBuffer.String(); out != "yep\n" {
+		t.Fatalf("unexpected stdout %q", out)
+	}
+}
+
+type shellCleanupCommand struct {
+	log *[]string
+}
+
+func (cmd *shellCleanupCommand) Help() cmdy.Help                                 { return cmdy.Synopsis("cleanup") }
+func (cmd *shellCleanupCommand) Configure(flags *cmdy.FlagSet, args *arg.ArgSet) {}
+
+func (cmd *shellCleanupCommand) Run(ctx cmdy.Context) error {
+	cmdy.OnCleanup(ctx, func(ctx context.Context) error {
+		*cmd.log = append(*cmd.log, "cleanup")
+		return nil
+	})
+	*cmd.log = append(*cmd.log, "after-register")
+	return nil
+}
+
+func TestShellCommandCleanups(t *testing.T) {
+	var log []string
+	var root cmdy.Builder
+	root = func() cmdy.Command {
+		return cmdy.NewGroup("test", cmdy.Builders{
+			"do":    func() cmdy.Command { return &shellCleanupCommand{log: &log} },
+			"shell": ShellCommand(func() cmdy.Command { return root() }),
+		})
+	}
+
+	runner := cmdy.NewBufferedRunner()
+	runner.StdinBuffer.WriteString("do\ndo\n")
+	if err := runner.Run(context.Background(), "test", []string{"shell"}, root); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each command run by the Shell has its own Cleanups, which run when it
+	// returns rather than when it registers them:
+	expected := []string{"after-register", "cleanup", "after-register", "cleanup"}
+	if !reflect.DeepEqual(expected, log) {
+		t.Fatalf("expected %q, found %q", expected, log)
+	}
+}
+
+type chanLineReader struct {
+	lines chan string
+}
+
+func (c *chanLineReader) ReadLine(prompt string) (string, error) {
+	line, ok := <-c.lines
+	if !ok {
+		return "", io.EOF
+	}
+	return line, nil
+}
+
+func TestShellInterruptCancelsCommand(t *testing.T) {
+	started := make(chan struct{})
+	lr := &chanLineReader{lines: make(chan string, 2)}
+	runner := cmdy.NewBufferedRunner()
+	shell := Shell{Builder: shellTestBuilder(started), Runner: &runner.Runner, LineReader: lr}
+
+	sig := make(chan os.Signal)
+	done := make(chan error)
+	go func() { done <- shell.run(context.Background(), sig) }()
+
+	lr.lines <- "wait"
+	<-started
+	sig <- os.Interrupt
+
+	// The shell is still running after the interrupt:
+	lr.lines <- "echo still here"
+	close(lr.lines)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if out := runner.StdoutBuffer.String(); out != "cancelled\nstill|here\n" {
+		t.Fatalf("unexpected stdout %q", out)
+	}
+}
+
+func TestShellContextCancelledWhileReading(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	lr := &chanLineReader{lines: make(chan string)}
+	runner := cmdy.NewBufferedRunner()
+	shell := Shell{Builder: shellTestBuilder(nil), Runner: &runner.Runner, LineReader: lr}
+
+	done := make(chan error)
+	go func() { done <- shell.run(ctx, make(chan os.Signal)) }()
+
+	// The LineReader never returns, but the Shell still stops:
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatal("expected context.Canceled, found", err)
+	}
+}
+
+func TestShellHistoryFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "history")
+
+	run := func(input string) (*Shell, *cmdy.BufferedRunner) {
+		runner := cmdy.NewBufferedRunner()
+		runner.StdinBuffer.WriteString(input)
+		shell := &Shell{Builder: shellTestBuilder(nil), Runner: &runner.Runner, HistoryFile: file, HistorySize: 3}
+		if err := shell.Run(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		return shell, runner
+	}
+
+	run("echo 1\necho 1\necho '2\n3'\n")
+	shell, runner := run("echo 4\nhistory\n")
+
+	expected := []string{"echo 1", "echo '2\n3'", "echo 4", "history"}
+	if h := shell.History(); !reflect.DeepEqual(expected[1:], h) {
+		t.Fatalf("expected history %q, found %q", expected[1:], h)
+	}
+	if out := runner.StdoutBuffer.String(); out != "4\n    1  echo '2\n3'\n    2  echo 4\n    3  history\n" {
+		t.Fatalf("unexpected history output %q", out)
+	}
+
+	// The file is trimmed when it is next loaded:
+	run("")
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "\"echo '2\\n3'\"\necho 4\nhistory\n" {
+		t.Fatalf("unexpected history file %q", data)
+	}
+}
+
+func TestShellComplete(t *testing.T) {
+	shell := Shell{Builder: shellTestBuilder(nil)}
+	for idx, tc := range []struct {
+		line     string
+		expected []string
+	}{
+		{"", []string{"echo", "exit", "history", "quit", "shell", "sub", "wait"}},
+		{"e", []string{"echo", "exit"}},
+		{"sub ", []string{"echo"}},
+		{"sub e", []string{"echo"}},
+		{"sub echo -", []string{"-upper"}},
+		{"sub echo --u", []string{"--upper"}},
+		{"echo -upper foo ", nil},
+		{"nope ", nil},
+		{"echo 'unterminated", nil},
+	} {
+		if result := shell.Complete(tc.line); !reflect.DeepEqual(tc.expected, result) {
+			t.Fatalf("%d: expected %q, found %q", idx, tc.expected, result)
+		}
+	}
+}
@@ -3,8 +3,10 @@ package cmdyutil
 import (
 	"context"
 	"errors"
+	"fmt"
 	"os"
 	"os/signal"
+	"sync"
 	"time"
 
 	"github.com/shabbyrobe/cmdy"
@@ -21,6 +23,14 @@ type InterruptRunnerOption func(i *InterruptRunner)
 //
 // If you send another interrupt while InterruptRunner is waiting for your command to
 // exit, it aborts your command immediately and does not wait for shutdown to complete.
+// Cleanup functions registered with cmdy.OnCleanup are still run before Run
+// returns, even though the command may still be running.
+//
+// By default, os.Interrupt, SIGTERM and SIGQUIT shut the command down. The
+// signals can be changed using InterruptOn. Signals such as SIGHUP can be
+// delivered to the command as reload events instead using InterruptReloadOn
+// (see Reloads). The signals received so far are available from the
+// command's Context using ReceivedSignals.
 //
 // NOTE: This API is experimental.
 type InterruptRunner struct {
@@ -28,6 +38,8 @@ type InterruptRunner struct {
 	timeout   time.Duration
 	onAbort   error
 	onTimeout error
+	signals   []os.Signal
+	reload    []os.Signal
 }
 
 // InterruptTimeout allows you to configure how long the Runner will wait
@@ -44,9 +56,29 @@ func InterruptTimeoutErr(err error) InterruptRunnerOption {
 	return func(i *InterruptRunner) { i.onTimeout = err }
 }
 
+// InterruptOn replaces the signals that shut the command down. If no signals
+// are passed, the command is only stopped if the Context passed to Run is
+// cancelled.
+func InterruptOn(sigs ...os.Signal) InterruptRunnerOption {
+	return func(i *InterruptRunner) { i.signals = sigs }
+}
+
+// InterruptReloadOn sets the signals that are delivered to the command as
+// reload events, for example:
+//
+//	cmdyutil.InterruptReloadOn(syscall.SIGHUP)
+//
+// Reload events are disabled by default, so that signals like SIGHUP keep
+// their default behaviour (stopping the program) for commands that do not
+// receive from Reloads.
+func InterruptReloadOn(sigs ...os.Signal) InterruptRunnerOption {
+	return func(i *InterruptRunner) { i.reload = sigs }
+}
+
 func NewInterruptRunner(runner *cmdy.Runner, opts ...InterruptRunnerOption) *InterruptRunner {
 	rn := &InterruptRunner{
 		Runner:    runner,
+		signals:   defaultInterruptSignals,
 		onAbort:   ErrInterruptAborted,
 		onTimeout: ErrInterruptTimeout,
 	}
@@ -65,30 +97,88 @@ func InterruptibleRun(ctx context.Context, args []string, b cmdy.Builder) (rerr
 	return NewInterruptRunner(cmdy.DefaultRunner()).Run(ctx, cmdy.ProgName(), args, b)
 }
 
-// Run the command created by builder. If the program receives an os.Interrupt,
-// ctx will be cancelled. If your command does not handle the 'ctx.Done()' condition
-// in time, Run will return an error.
+// Run the command created by builder. If the program receives one of the
+// InterruptRunner's signals, or if ctx is cancelled, the command's Context
+// will be cancelled. If your command does not handle the 'ctx.Done()'
+// condition in time, Run will return an error.
+//
+// If the command returns an error that wraps context.Canceled after a signal
+// was received, Run returns a *SignalError instead, so the program exits with
+// the conventional code for the signal (i.e. 143 for SIGTERM). If the
+// command does not return before the InterruptTimeout, or another signal is
+// received while waiting for it, Run returns ErrInterruptTimeout or
+// ErrInterruptAborted (unless replaced using InterruptTimeoutErr or
+// InterruptAbortErr), whatever the signal was.
 //
 // A goroutine will be leaked if your command never completes in response to the
 // Interrupt.
 func (r *InterruptRunner) Run(ctx context.Context, name string, args []string, builder cmdy.Builder) (rerr error) {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	// signal.Notify with no signals relays all of them, which is not what an
+	// empty InterruptOn means:
+	sig := make(chan os.Signal, 2)
+	if len(r.signals) > 0 {
+		signal.Notify(sig, r.signals...)
+		defer signal.Stop(sig)
+	}
 
-	done := make(chan error, 1)
+	var reload chan os.Signal
+	if len(r.reload) > 0 {
+		reload = make(chan os.Signal, 1)
+		signal.Notify(reload, r.reload...)
+		defer signal.Stop(reload)
+	}
+
+	return r.run(ctx, name, args, builder, sig, reload)
+}
+
+func (r *InterruptRunner) run(
+	ctx context.Context,
+	name string,
+	args []string,
+	builder cmdy.Builder,
+	sig <-chan os.Signal,
+	reload <-chan os.Signal,
+) (rerr error) {
+	state := &signalState{reload: reload}
+	ctx = context.WithValue(ctx, signalStateKey{}, state)
 
-	sig := make(chan os.Signal, 1)
-	signal.Notify(sig, os.Interrupt)
+	// Cleanups registered by the command are run by the Runner before its
+	// services are closed. They are also run here, so they still run if we
+	// give up waiting for the command; Cleanups.Run only runs them once:
+	cleanups := &cmdy.Cleanups{}
+	ctx = cmdy.WithCleanups(ctx, cleanups)
+	defer func() {
+		if err := cleanups.Run(); err != nil && rerr == nil {
+			rerr = err
+		}
+	}()
 
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := make(chan error, 1)
 	go func() {
 		done <- r.Runner.Run(ctx, name, args, builder)
 	}()
 
-	select {
-	case <-sig:
-		cancel()
-	case err := <-done:
-		return err
+	var first os.Signal
+wait:
+	for {
+		select {
+		case first = <-sig:
+			if state.intercept(first) {
+				first = nil
+				continue
+			}
+			state.add(first)
+			cancel()
+		case <-ctx.Done():
+			// The parent context was cancelled; wait for shutdown as if a signal
+			// had been received.
+		case err := <-done:
+			return err
+		}
+		break wait
 	}
 
 	timeout := r.timeout
@@ -96,18 +186,134 @@ func (r *InterruptRunner) Run(ctx context.Context, name string, args []string, b
 		timeout = DefaultInterruptTimeout
 	}
 
-	wait := time.After(timeout)
+	wait := time.NewTimer(timeout)
+	defer wait.Stop()
 
 	select {
 	case err := <-done:
+		if first != nil && errors.Is(err, context.Canceled) {
+			return &SignalError{Signal: first, Err: err}
+		}
 		return err
-	case <-sig:
-		return ErrInterruptAborted
-	case <-wait:
-		return ErrInterruptTimeout
+
+	case second := <-sig:
+		state.add(second)
+		return r.onAbort
+
+	case <-wait.C:
+		return r.onTimeout
 	}
 }
 
+type signalStateKey struct{}
+
+type signalState struct {
+	mu       sync.Mutex
+	received []os.Signal
+	reload   <-chan os.Signal
+
+	// interceptor, if set, is offered each signal before it shuts the
+	// command down; see interceptSignals.
+	interceptor func(sig os.Signal) bool
+}
+
+func (s *signalState) intercept(sig os.Signal) bool {
+	s.mu.Lock()
+	interceptor := s.interceptor
+	s.mu.Unlock()
+	return interceptor != nil && interceptor(sig)
+}
+
+// interceptSignals allows a command run by an InterruptRunner to handle some
+// of the InterruptRunner's shutdown signals itself. fn is called with each
+// signal received before shutdown starts; if it returns true, the signal is
+// not treated as a shutdown signal. ok is false if ctx does not come from an
+// InterruptRunner. Call stop to restore the default behaviour.
+func interceptSignals(ctx context.Context, fn func(sig os.Signal) bool) (stop func(), ok bool) {
+	state, _ := ctx.Value(signalStateKey{}).(*signalState)
+	if state == nil {
+		return nil, false
+	}
+	state.mu.Lock()
+	prev := state.interceptor
+	state.interceptor = fn
+	state.mu.Unlock()
+
+	return func() {
+		state.mu.Lock()
+		state.interceptor = prev
+		state.mu.Unlock()
+	}, true
+}
+
+func (s *signalState) add(sig os.Signal) {
+	s.mu.Lock()
+	s.received = append(s.received, sig)
+	s.mu.Unlock()
+}
+
+// ReceivedSignals returns the shutdown signals received by the InterruptRunner
+// running the command, in the order they were received. The first signal
+// cancels the Context; a second signal aborts the command. Commands can use it
+// to report why they stopped.
+//
+// ReceivedSignals returns nil if the command is not run by an InterruptRunner.
+func ReceivedSignals(ctx context.Context) []os.Signal {
+	state, _ := ctx.Value(signalStateKey{}).(*signalState)
+	if state == nil {
+		return nil
+	}
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	return append([]os.Signal(nil), state.received...)
+}
+
+// Reloads returns a channel that receives the InterruptRunner's reload signals
+// (see InterruptReloadOn), so long-running commands can reload their
+// configuration:
+//
+//	for {
+//		select {
+//		case <-cmdyutil.Reloads(ctx):
+//			reloadConfig()
+//		case <-ctx.Done():
+//			return ctx.Err()
+//		}
+//	}
+//
+// Reload signals are dropped if the command is not ready to receive them. The
+// channel is nil (so receiving from it blocks forever) if the command is not run
+// by an InterruptRunner, or if reload events are disabled.
+func Reloads(ctx context.Context) <-chan os.Signal {
+	state, _ := ctx.Value(signalStateKey{}).(*signalState)
+	if state == nil {
+		return nil
+	}
+	return state.reload
+}
+
+// SignalExitCode returns the conventional exit code for a process that was
+// stopped by sig, 128 plus the signal's number. If the number can not be
+// determined, ExitInterrupt is returned.
+func SignalExitCode(sig os.Signal) int {
+	if num, ok := signalNumber(sig); ok {
+		return 128 + num
+	}
+	return ExitInterrupt
+}
+
+// SignalError is returned by InterruptRunner.Run if the command stopped with
+// context.Canceled after a signal was received. Its Code is the SignalExitCode
+// of the signal.
+type SignalError struct {
+	Signal os.Signal
+	Err    error
+}
+
+func (e *SignalError) Code() int     { return SignalExitCode(e.Signal) }
+func (e *SignalError) Unwrap() error { return e.Err }
+func (e *SignalError) Error() string { return fmt.Sprintf("stopped by signal: %v", e.Signal) }
+
 func IsInterruptErr(err error) bool {
 	return errors.Is(err, ErrInterruptAborted) || errors.Is(err, ErrInterruptTimeout)
 }
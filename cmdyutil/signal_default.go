@@ -0,0 +1,17 @@
+// +build !plan9,!js
+
+package cmdyutil
+
+import (
+	"os"
+	"syscall"
+)
+
+var defaultInterruptSignals = []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGQUIT}
+
+func signalNumber(sig os.Signal) (num int, ok bool) {
+	if s, ok := sig.(syscall.Signal); ok {
+		return int(s), true
+	}
+	return 0, false
+}
@@ -0,0 +1,17 @@
+// +build plan9 js
+
+package cmdyutil
+
+import (
+	"os"
+)
+
+// Platforms without SIGTERM or SIGQUIT only stop on os.Interrupt.
+var defaultInterruptSignals = []os.Signal{os.Interrupt}
+
+// Plan 9 uses notes rather than numbered signals, and js only has signals
+// which are emulated by the Go runtime, so SignalExitCode falls back to
+// ExitInterrupt.
+func signalNumber(sig os.Signal) (num int, ok bool) {
+	return 0, false
+}
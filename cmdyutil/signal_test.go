@@ -0,0 +1,323 @@
+//go:build !plan9 && !js
+// +build !plan9,!js
+
+package cmdyutil
+
+import (
+	"context"
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/shabbyrobe/cmdy"
+	"github.com/shabbyrobe/cmdy/arg"
+)
+
+type signalTestKey struct{}
+
+func signalTestBuilder(run func(ctx cmdy.Context) error) cmdy.Builder {
+	return func() cmdy.Command {
+		return &signalTestCommand{run: run}
+	}
+}
+
+type signalTestCommand struct {
+	run func(ctx cmdy.Context) error
+}
+
+func (cmd *signalTestCommand) Help() cmdy.Help                                 { return cmdy.Synopsis("func") }
+func (cmd *signalTestCommand) Configure(flags *cmdy.FlagSet, args *arg.ArgSet) {}
+func (cmd *signalTestCommand) Run(ctx cmdy.Context) error                      { return cmd.run(ctx) }
+
+func TestInterruptRunnerSignalExitCode(t *testing.T) {
+	sig := make(chan os.Signal, 2)
+	started := make(chan struct{})
+	var received []os.Signal
+
+	rn := NewInterruptRunner(&cmdy.NewBufferedRunner().Runner)
+	go func() {
+		<-started
+		sig <- syscall.SIGTERM
+	}()
+
+	ctx := context.WithValue(context.Background(), signalTestKey{}, "yep")
+	err := rn.run(ctx, "test", nil, signalTestBuilder(func(ctx cmdy.Context) error {
+		if ctx.Value(signalTestKey{}) != "yep" {
+			t.Error("parent context value not found")
+		}
+		close(started)
+		<-ctx.Done()
+		received = ReceivedSignals(ctx)
+		return ctx.Err()
+	}), sig, nil)
+
+	var serr *SignalError
+	if !errors.As(err, &serr) || serr.Signal != syscall.SIGTERM {
+		t.Fatal("expected SignalError, found", err)
+	}
+	if code := cmdy.ErrCode(err); code != 143 {
+		t.Fatal("expected code 143, found", code)
+	}
+	if len(received) != 1 || received[0] != syscall.SIGTERM {
+		t.Fatal("unexpected received signals", received)
+	}
+}
+
+func TestInterruptRunnerAbort(t *testing.T) {
+	sig := make(chan os.Signal, 2)
+	started := make(chan struct{})
+	block := make(chan struct{})
+	defer close(block)
+
+	rn := NewInterruptRunner(&cmdy.NewBufferedRunner().Runner, InterruptTimeout(time.Minute))
+	go func() {
+		<-started
+		sig <- syscall.SIGTERM
+		sig <- os.Interrupt
+	}()
+
+	err := rn.run(context.Background(), "test", nil, signalTestBuilder(func(ctx cmdy.Context) error {
+		close(started)
+		<-block
+		return nil
+	}), sig, nil)
+
+	if err != ErrInterruptAborted || !IsInterruptErr(err) {
+		t.Fatal("expected abort, found", err)
+	}
+	if code := cmdy.ErrCode(err); code != ExitInterrupt {
+		t.Fatal("expected code", ExitInterrupt, "found", code)
+	}
+}
+
+func TestInterruptRunnerTimeout(t *testing.T) {
+	sig := make(chan os.Signal, 2)
+	started := make(chan struct{})
+	block := make(chan struct{})
+	defer close(block)
+
+	custom := errors.New("too slow")
+	rn := NewInterruptRunner(&cmdy.NewBufferedRunner().Runner,
+		InterruptTimeout(time.Millisecond),
+		InterruptTimeoutErr(custom))
+	go func() {
+		<-started
+		sig <- os.Interrupt
+	}()
+
+	err := rn.run(context.Background(), "test", nil, signalTestBuilder(func(ctx cmdy.Context) error {
+		close(started)
+		<-block
+		return nil
+	}), sig, nil)
+
+	if err != custom {
+		t.Fatal("expected custom timeout error, found", err)
+	}
+}
+
+func TestInterruptRunnerTimeoutSentinel(t *testing.T) {
+	sig := make(chan os.Signal, 2)
+	started := make(chan struct{})
+	block := make(chan struct{})
+	defer close(block)
+
+	rn := NewInterruptRunner(&cmdy.NewBufferedRunner().Runner, InterruptTimeout(time.Millisecond))
+	go func() {
+		<-started
+		sig <- syscall.SIGTERM
+	}()
+
+	err := rn.run(context.Background(), "test", nil, signalTestBuilder(func(ctx cmdy.Context) error {
+		close(started)
+		<-block
+		return nil
+	}), sig, nil)
+
+	if err != ErrInterruptTimeout {
+		t.Fatal("expected ErrInterruptTimeout, found", err)
+	}
+}
+
+func TestInterruptRunnerParentCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	rn := NewInterruptRunner(&cmdy.NewBufferedRunner().Runner)
+
+	err := rn.run(ctx, "test", nil, signalTestBuilder(func(ctx cmdy.Context) error {
+		cancel()
+		<-ctx.Done()
+		return ctx.Err()
+	}), make(chan os.Signal), nil)
+
+	// No signal was received, so the error is passed through unchanged:
+	if err != context.Canceled {
+		t.Fatal("expected context.Canceled, found", err)
+	}
+}
+
+func TestInterruptRunnerReload(t *testing.T) {
+	reload := make(chan os.Signal, 1)
+	reload <- syscall.SIGHUP
+
+	rn := NewInterruptRunner(&cmdy.NewBufferedRunner().Runner)
+	err := rn.run(context.Background(), "test", nil, signalTestBuilder(func(ctx cmdy.Context) error {
+		select {
+		case sig := <-Reloads(ctx):
+			if sig != syscall.SIGHUP {
+				t.Error("unexpected signal", sig)
+			}
+		case <-time.After(5 * time.Second):
+			t.Error("reload not received")
+		}
+		return nil
+	}), make(chan os.Signal), reload)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInterruptRunnerReloadOptIn(t *testing.T) {
+	if rn := NewInterruptRunner(&cmdy.NewBufferedRunner().Runner); len(rn.reload) != 0 {
+		t.Fatal("expected no reload signals by default, found", rn.reload)
+	}
+	rn := NewInterruptRunner(&cmdy.NewBufferedRunner().Runner, InterruptReloadOn(syscall.SIGHUP))
+	if len(rn.reload) != 1 || rn.reload[0] != syscall.SIGHUP {
+		t.Fatal("unexpected reload signals", rn.reload)
+	}
+}
+
+func TestSignalExitCode(t *testing.T) {
+	for sig, code := range map[os.Signal]int{
+		os.Interrupt:    130,
+		syscall.SIGTERM: 143,
+		syscall.SIGHUP:  129,
+	} {
+		if found := SignalExitCode(sig); found != code {
+			t.Fatal(sig, "expected", code, "found", found)
+		}
+	}
+}
+
+func TestInterruptRunnerAbortRunsCleanups(t *testing.T) {
+	sig := make(chan os.Signal, 2)
+	started := make(chan struct{})
+	block := make(chan struct{})
+	defer close(block)
+
+	var cleaned bool
+	rn := NewInterruptRunner(&cmdy.NewBufferedRunner().Runner, InterruptTimeout(time.Minute))
+	go func() {
+		<-started
+		sig <- os.Interrupt
+		sig <- os.Interrupt
+	}()
+
+	err := rn.run(context.Background(), "test", nil, signalTestBuilder(func(ctx cmdy.Context) error {
+		cmdy.OnCleanup(ctx, func(ctx context.Context) error {
+			cleaned = true
+			return nil
+		})
+		close(started)
+		<-block
+		return nil
+	}), sig, nil)
+
+	if !errors.Is(err, ErrInterruptAborted) {
+		t.Fatal("expected abort, found", err)
+	}
+	if !cleaned {
+		t.Fatal("cleanup not run")
+	}
+}
+
+type signalTestService struct{ closed bool }
+
+func (s *signalTestService) Close() error { s.closed = true; return nil }
+
+func TestInterruptRunnerCleanupsBeforeServicesClose(t *testing.T) {
+	var services cmdy.Services
+	services.Provide(func(ctx cmdy.Context) *signalTestService {
+		return &signalTestService{}
+	})
+	runner := cmdy.NewBufferedRunner()
+	runner.Services = &services
+
+	var closedInCleanup, cleaned bool
+	rn := NewInterruptRunner(&runner.Runner)
+	err := rn.run(context.Background(), "test", nil, signalTestBuilder(func(ctx cmdy.Context) error {
+		var svc *signalTestService
+		if err := cmdy.Resolve(ctx, &svc); err != nil {
+			return err
+		}
+		cmdy.OnCleanup(ctx, func(ctx context.Context) error {
+			cleaned, closedInCleanup = true, svc.closed
+			return nil
+		})
+		return nil
+	}), make(chan os.Signal), nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cleaned {
+		t.Fatal("cleanup not run")
+	}
+	if closedInCleanup {
+		t.Fatal("service closed before cleanup ran")
+	}
+}
+
+// ctxLineReader stops reading lines when ctx is done.
+type ctxLineReader struct {
+	ctx   context.Context
+	lines chan string
+}
+
+func (c *ctxLineReader) ReadLine(prompt string) (string, error) {
+	select {
+	case line := <-c.lines:
+		return line, nil
+	case <-c.ctx.Done():
+		return "", c.ctx.Err()
+	}
+}
+
+func TestInterruptRunnerShellInterrupt(t *testing.T) {
+	sig := make(chan os.Signal)
+	started := make(chan struct{})
+	lines := make(chan string)
+	shellRunner := cmdy.NewBufferedRunner()
+
+	rn := NewInterruptRunner(&cmdy.NewBufferedRunner().Runner)
+	done := make(chan error)
+	go func() {
+		done <- rn.run(context.Background(), "test", nil, signalTestBuilder(func(ctx cmdy.Context) error {
+			lr := &ctxLineReader{ctx: ctx, lines: lines}
+			shell := Shell{Builder: shellTestBuilder(started), Runner: &shellRunner.Runner, LineReader: lr}
+			return shell.Run(ctx)
+		}), sig, nil)
+	}()
+
+	lines <- "wait"
+	<-started
+
+	// The Shell takes over os.Interrupt, so only the running command is
+	// cancelled:
+	sig <- os.Interrupt
+	lines <- "echo still here"
+	lines <- "# wait for the echo to finish"
+
+	// Other signals still stop the Shell:
+	sig <- syscall.SIGTERM
+
+	var serr *SignalError
+	if err := <-done; !errors.As(err, &serr) || serr.Signal != syscall.SIGTERM {
+		t.Fatal("expected SignalError, found", err)
+	}
+	if out := shellRunner.StdoutBuffer.String(); out != "cancelled\nstill|here\n" {
+		t.Fatalf("unexpected stdout %q", out)
+	}
+}
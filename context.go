@@ -2,8 +2,11 @@ package cmdy
 
 import (
 	"context"
+	"flag"
 	"io"
 	"strings"
+
+	"github.com/shabbyrobe/cmdy/arg"
 )
 
 // Context implements context.Context; Context is passed into all commands
@@ -48,6 +51,13 @@ type commandContext struct {
 	rawArgs []string
 	runner  *Runner
 	parents []CommandRef
+
+	// services holds the values constructed from Runner.Services for the
+	// lifetime of the outermost call to Runner.Run.
+	services *serviceScope
+
+	// Path to the innermost command that returned an error, see failedRuns:
+	failedPath []string
 }
 
 func (c *commandContext) RawArgs() []string { return c.rawArgs }
@@ -69,6 +79,16 @@ func (c *commandContext) Push(name string, cmd Command) {
 	c.parents = append(c.parents, CommandRef{Name: name, Command: cmd})
 }
 
+// currentScope returns the innermost serviceScope on the stack.
+func (c *commandContext) currentScope() *serviceScope {
+	for i := len(c.parents) - 1; i >= 0; i-- {
+		if c.parents[i].scope != nil {
+			return c.parents[i].scope
+		}
+	}
+	return c.services
+}
+
 func (c *commandContext) Pop() (name string, cmd Command) {
 	var item CommandRef
 	pl := len(c.parents)
@@ -81,6 +101,15 @@ func (c *commandContext) Pop() (name string, cmd Command) {
 type CommandRef struct {
 	Name    string
 	Command Command
+
+	// Flags and Args are set by Runner.Run once the Command has been
+	// configured. They are nil for commands which have not been run by a
+	// Runner (for example, in the path passed to Help for the help command).
+	Flags *FlagSet
+	Args  *arg.ArgSet
+
+	// Services registered using Scope while the Command is running:
+	scope *serviceScope
 }
 
 type CommandPath []CommandRef
@@ -89,6 +118,27 @@ func (cp CommandPath) Invocation() string {
 	return strings.Join(cp.Names(), " ")
 }
 
+// Flag looks up a flag by name in the FlagSets of the commands in the path,
+// starting with the innermost. It returns nil if no command defines the flag.
+//
+// This can be used by a subcommand to find the values of flags passed to its
+// parent Groups:
+//
+//	if fl := ctx.Stack().Flag("config"); fl != nil {
+//		config = fl.Value.String()
+//	}
+func (cp CommandPath) Flag(name string) *flag.Flag {
+	for i := len(cp) - 1; i >= 0; i-- {
+		if cp[i].Flags == nil {
+			continue
+		}
+		if fl := cp[i].Flags.Lookup(name); fl != nil {
+			return fl
+		}
+	}
+	return nil
+}
+
 func (cp CommandPath) Names() []string {
 	out := make([]string, len(cp))
 	for i, r := range cp {
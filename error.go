@@ -1,7 +1,6 @@
 package cmdy
 
 import (
-	"errors"
 	"fmt"
 	"strings"
 )
@@ -26,6 +25,25 @@ const (
 	ExitInternal = 255
 )
 
+// Exit codes from sysexits.h. Descriptions of each are available from
+// ExitCodeDescription. ExitUsage is also from sysexits.h.
+const (
+	ExitDataError    = 65 // EX_DATAERR: input data was incorrect
+	ExitNoInput      = 66 // EX_NOINPUT: an input file did not exist or was not readable
+	ExitNoUser       = 67 // EX_NOUSER: user does not exist
+	ExitNoHost       = 68 // EX_NOHOST: host does not exist
+	ExitUnavailable  = 69 // EX_UNAVAILABLE: a service is unavailable
+	ExitSoftware     = 70 // EX_SOFTWARE: internal software error
+	ExitOSError      = 71 // EX_OSERR: operating system error
+	ExitOSFile       = 72 // EX_OSFILE: a system file was missing or invalid
+	ExitCantCreate   = 73 // EX_CANTCREAT: an output file could not be created
+	ExitIOError      = 74 // EX_IOERR: an error occurred while doing I/O
+	ExitTempFail     = 75 // EX_TEMPFAIL: temporary failure; try again later
+	ExitProtocol     = 76 // EX_PROTOCOL: remote system returned a protocol error
+	ExitNoPermission = 77 // EX_NOPERM: permission denied
+	ExitConfig       = 78 // EX_CONFIG: configuration error
+)
+
 type Error interface {
 	Code() int
 	error
@@ -69,30 +87,30 @@ func UsageErrorf(msg string, args ...interface{}) error {
 	return &usageError{err: fmt.Errorf(msg, args...)}
 }
 
-// IsHelpRequest returns true if err (or the result of errors.Unwrap) is a help request.
+// IsHelpRequest returns true if err, or any error it wraps, is a help request.
 func IsHelpRequest(err error) bool {
-	var u *usageError
-	if !errors.As(err, &u) {
-		return false
-	}
-	return u.helpRequest
+	u := findUsageError(err)
+	return u != nil && u.helpRequest
 }
 
-// IsUsageError returns true if err (or the result of errors.Unwrap) is a usage error.
+// IsUsageError returns true if err, or any error it wraps, is a usage error.
 // This includes help requests.
 func IsUsageError(err error) bool {
-	var u *usageError
-	return errors.As(err, &u)
+	return findUsageError(err) != nil
 }
 
-// ErrCode returns the error code associated with the error if it implements
-// cmdy.Error, or ExitInternal if not.
+// ErrCode returns the error code associated with the error if it, or any
+// error it wraps, implements cmdy.Error, or ExitInternal if not. If more than
+// one error in the chain implements cmdy.Error, the outermost one is used, so
+// wrapping an error with an explicit code (for example, using ErrWithCode)
+// overrides the code of the error it wraps. The members of multi-errors are
+// searched in order.
 func ErrCode(err error) (code int) {
 	if err == nil {
 		return ExitSuccess
 	}
-	e, ok := err.(Error)
-	if !ok {
+	e := findCodedError(err)
+	if e == nil {
 		return ExitInternal
 	}
 	return e.Code()
@@ -100,64 +118,208 @@ func ErrCode(err error) (code int) {
 
 // FormatError builds the output which should be printed to the console.
 //
+// Errors are unwrapped using 'Unwrap() error', 'Unwrap() []error' (as
+// returned by errors.Join in Go 1.20 or later) and 'Errors() []error', so
+// wrapping an error using fmt.Errorf's '%w' verb does not change how it is
+// formatted. code is taken from the outermost error that implements
+// cmdy.Error (see ErrCode), or is ExitFailure if there isn't one.
+//
 // If the error is a usage error, the full help string will be assigned
 // to msg, and if the usage error wraps another error, the text will be
-// included at the end.
+// included at the end. If the usage error is in an error group, the group's
+// errors are listed at the end instead.
 //
-// If the error contains an 'Errors() []error' method, each individual
-// error is printed in a list.
+// If the error contains an 'Errors() []error' or 'Unwrap() []error' method,
+// each individual error is printed in a list.
 //
 // If the error is a QuietExit, msg is empty but code will be set to the
-// status code.
+// status code. An error group is only quiet if all of its errors are; quiet
+// errors are otherwise left out of the list.
+//
+// If the error is a *DetailedError, its Hint and Doc are printed on separate
+// lines after the message.
 //
 // Otherwise, msg will contain the result of calling Error().
 //
+// FormatError uses the default Messages; use Runner.FormatError to use the
+// Runner's Messages instead.
+//
 func FormatError(err error) (msg string, code int) {
+	return formatError(err, defaultMessages)
+}
+
+// FormatError is like the FormatError function, but uses the Runner's
+// Messages.
+func (r *Runner) FormatError(err error) (msg string, code int) {
+	return formatError(err, r.messages())
+}
+
+func formatError(err error, msgs *Messages) (msg string, code int) {
 	if err == nil {
 		return "", ExitSuccess
 	}
 
-	switch err := err.(type) {
-	case QuietExit:
-		// If we don't return here, a code of '0' will be interpreted as an
-		// ExitFailure. In the case of QuietExit, it's a little bit less
-		// natural to assume '0' means we want a non-zero exit status even
-		// though we are technically returning an error.
-		return "", err.Code()
+	// If we don't use the code from the error, a QuietExit of '0' will be
+	// interpreted as an ExitFailure. In the case of QuietExit, it's a little
+	// bit less natural to assume '0' means we want a non-zero exit status
+	// even though we are technically returning an error.
+	code = ExitFailure
+	if coded := findCodedError(err); coded != nil {
+		code = coded.Code()
+	}
+
+	if isQuietError(err) {
+		return "", code
+	}
 
-	case *usageError:
+	if uerr := findUsageError(err); uerr != nil {
 		// usageError.usage is lazily populated in Runner.Run() before it is returned:
-		msg = strings.TrimSpace(err.usage)
-		code = err.Code()
+		msg = strings.TrimSpace(uerr.usage)
 
-		if err.err != nil {
+		// The other errors in a group are not dropped in favour of the usage:
+		if errs := groupErrors(err); errs != nil {
+			if list := formatGroup(errs); list != "" {
+				if msg != "" {
+					msg += "\n\n"
+				}
+				msg += list
+			}
+			return msg, code
+		}
+
+		msgs := uerr.messages()
+		var text string
+		if err != error(uerr) {
+			// The error has been wrapped, so the wrapper's message is more
+			// informative than the usage error's:
+			text = err.Error()
+		} else if uerr.err != nil {
+			text = msgs.errorText(uerr.err)
+		}
+		if text != "" {
 			if msg != "" {
 				msg += "\n\n"
 			}
-			msg += "error: " + err.err.Error()
+			msg += fmt.Sprintf(msgs.Error, text)
 		}
 		return msg, code
+	}
+
+	if errs := groupErrors(err); errs != nil {
+		return formatGroup(errs), code
+	}
+
+	msg = err.Error()
+	if found := findError(err, isDetailedError); found != nil {
+		derr := found.(*DetailedError)
+		if derr.Hint != "" {
+			msg += "\n" + fmt.Sprintf(msgs.ErrorHint, derr.Hint)
+		}
+		if derr.Doc != "" {
+			msg += "\n" + fmt.Sprintf(msgs.ErrorDoc, derr.Doc)
+		}
+	}
+	return msg, code
+}
+
+// formatGroup lists the members of an error group, one per line. Quiet
+// members are left out.
+func formatGroup(errs []error) (msg string) {
+	for _, e := range errs {
+		if isQuietError(e) {
+			continue
+		}
+		if msg != "" {
+			msg += "\n"
+		}
+		msg += "- " + e.Error()
+	}
+	return msg
+}
+
+// findError returns the first error in err's tree for which match returns
+// true. The tree is formed by 'Unwrap() error', 'Unwrap() []error' and
+// 'Errors() []error', and is searched depth-first, starting with err itself,
+// as errors.As does in Go 1.20 and later. Unlike errors.As, this works with
+// earlier versions of Go.
+func findError(err error, match func(err error) bool) error {
+	if err == nil {
+		return nil
+	}
+	if match(err) {
+		return err
+	}
+	for _, e := range childErrors(err) {
+		if found := findError(e, match); found != nil {
+			return found
+		}
+	}
+	return nil
+}
 
-	case Error:
-		return err.Error(), err.Code()
+// findCodedError returns the outermost error in err's tree that implements
+// Error. See findError.
+func findCodedError(err error) Error {
+	coded, _ := findError(err, isCodedError).(Error)
+	return coded
+}
 
+func childErrors(err error) []error {
+	switch u := err.(type) {
+	case interface{ Unwrap() error }:
+		return []error{u.Unwrap()}
+	case interface{ Unwrap() []error }:
+		return u.Unwrap()
 	case errorGroup:
-		withCode, _ := err.(interface{ Code() int })
-		code = ExitFailure
-		if withCode != nil {
-			code = withCode.Code()
+		return u.Errors()
+	}
+	return nil
+}
+
+func findUsageError(err error) *usageError {
+	u, _ := findError(err, isUsageError).(*usageError)
+	return u
+}
+
+func isCodedError(err error) bool    { _, ok := err.(Error); return ok }
+func isUsageError(err error) bool    { _, ok := err.(*usageError); return ok }
+func isDetailedError(err error) bool { _, ok := err.(*DetailedError); return ok }
+
+// isQuietError returns true if err is, or wraps, a QuietExit. A QuietExit
+// in an error group only makes the group quiet if all of its members are
+// quiet, so that it doesn't hide the others.
+func isQuietError(err error) bool {
+	for err != nil {
+		if _, ok := err.(QuietExit); ok {
+			return true
 		}
-		for i, e := range err.Errors() {
-			if i != 0 {
-				msg += "\n"
+		if errs := groupErrors(err); errs != nil {
+			for _, e := range errs {
+				if !isQuietError(e) {
+					return false
+				}
 			}
-			msg += "- " + e.Error()
+			return len(errs) > 0
 		}
-		return msg, code
+		u, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			return false
+		}
+		err = u.Unwrap()
+	}
+	return false
+}
 
-	default:
-		return err.Error(), ExitFailure
+// groupErrors returns the errors contained in err if it implements
+// 'Errors() []error' or 'Unwrap() []error'.
+func groupErrors(err error) []error {
+	switch g := err.(type) {
+	case errorGroup:
+		return g.Errors()
+	case interface{ Unwrap() []error }:
+		return g.Unwrap()
 	}
+	return nil
 }
 
 type exitError struct {
@@ -173,10 +335,21 @@ type usageError struct {
 	err         error
 	usage       string
 	helpRequest bool
+
+	// msgs is populated alongside usage in Runner.Run(), so the error can be
+	// formatted in the Runner's language.
+	msgs *Messages
 }
 
 func (u *usageError) Unwrap() error { return u.err }
 
+func (u *usageError) messages() *Messages {
+	if u.msgs == nil {
+		return defaultMessages
+	}
+	return u.msgs
+}
+
 func (u *usageError) Code() int {
 	if u.helpRequest {
 		return 0
@@ -187,9 +360,9 @@ func (u *usageError) Code() int {
 
 func (u *usageError) Error() string {
 	if u.helpRequest {
-		return "help requested"
+		return u.messages().HelpRequested
 	} else if u.err == nil {
-		return "usage error"
+		return u.messages().UsageError
 	}
 	return u.err.Error()
 }
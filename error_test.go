@@ -1,8 +1,10 @@
 package cmdy
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/shabbyrobe/cmdy/internal/assert"
@@ -43,3 +45,123 @@ func TestUsageErrorCanUnwrap(t *testing.T) {
 	uerr := UsageError(err)
 	tt.MustEqual(err, errors.Unwrap(uerr))
 }
+
+type testMultiError []error
+
+func (m testMultiError) Error() string   { return "multi" }
+func (m testMultiError) Unwrap() []error { return m }
+
+func TestErrCodeUnwraps(t *testing.T) {
+	tt := assert.WrapTB(t)
+
+	tt.MustEqual(ExitUsage, ErrCode(fmt.Errorf("ctx: %w", UsageErrorf("bad"))))
+	tt.MustEqual(3, ErrCode(fmt.Errorf("ctx: %w", QuietExit(3))))
+	tt.MustEqual(ExitInternal, ErrCode(fmt.Errorf("ctx: %w", errors.New("boom"))))
+
+	// The outermost code wins, so wrapping an error with an explicit code
+	// overrides the code of the error it wraps:
+	tt.MustEqual(4, ErrCode(ErrWithCode(4, fmt.Errorf("ctx: %w", QuietExit(3)))))
+	tt.MustEqual(2, ErrCode(ErrWithCode(2, ErrWithCode(5, errors.New("boom")))))
+	tt.MustEqual(ExitConfig, ErrCode(NewError(CategoryConfig, ErrWithCode(5, errors.New("boom")))))
+	tt.MustEqual(4, ErrCode(fmt.Errorf("ctx: %w", ErrWithCode(4, NewError(CategoryConfig, errors.New("boom"))))))
+	tt.MustEqual(ExitUsage, ErrCode(fmt.Errorf("ctx: %w", UsageError(QuietExit(3)))))
+
+	// Multi-errors are searched in order:
+	tt.MustEqual(5, ErrCode(testMultiError{errors.New("a"), QuietExit(5), QuietExit(6)}))
+	tt.MustEqual(4, ErrCode(ErrWithCode(4, testMultiError{errors.New("a"), QuietExit(5)})))
+	tt.MustEqual(5, ErrCode(fmt.Errorf("ctx: %w", testMultiError{errors.New("a"), QuietExit(5)})))
+}
+
+func TestErrCodeErrorGroup(t *testing.T) {
+	tt := assert.WrapTB(t)
+
+	// Members of groups that only implement 'Errors() []error' are searched
+	// too:
+	tt.MustEqual(5, ErrCode(testErrorGroup{errors.New("a"), QuietExit(5), QuietExit(6)}))
+	tt.MustEqual(ExitInternal, ErrCode(testErrorGroup{errors.New("a")}))
+
+	msg, code := FormatError(testErrorGroup{errors.New("a"), ErrWithCode(3, errors.New("b"))})
+	tt.MustEqual("- a\n- b", msg)
+	tt.MustEqual(3, code)
+
+	tt.MustAssert(IsUsageError(testErrorGroup{errors.New("a"), UsageErrorf("b")}))
+}
+
+func TestFormatErrorGroupQuietExit(t *testing.T) {
+	tt := assert.WrapTB(t)
+
+	// A QuietExit in a group does not hide the group's other errors:
+	msg, code := FormatError(testMultiError{errors.New("a"), QuietExit(3)})
+	tt.MustEqual("- a", msg)
+	tt.MustEqual(3, code)
+
+	// ...but a group of QuietExits is quiet:
+	msg, code = FormatError(testErrorGroup{QuietExit(3), fmt.Errorf("ctx: %w", QuietExit(4))})
+	tt.MustEqual("", msg)
+	tt.MustEqual(3, code)
+
+	rep := NewErrorReport(testMultiError{errors.New("a"), QuietExit(3)}, nil)
+	tt.MustEqual(ErrorKindGroup, rep.Kind)
+	tt.MustEqual([]string{"a"}, rep.Errors)
+}
+
+func TestFormatErrorGroupUsageError(t *testing.T) {
+	tt := assert.WrapTB(t)
+
+	uerr := &usageError{err: errors.New("bad"), usage: "Usage: test"}
+	msg, code := FormatError(testMultiError{errors.New("a"), uerr})
+	tt.MustEqual("Usage: test\n\n- a\n- bad", msg)
+	tt.MustEqual(ExitUsage, code)
+}
+
+func TestFormatErrorWrapped(t *testing.T) {
+	tt := assert.WrapTB(t)
+
+	msg, code := FormatError(fmt.Errorf("ctx: %w", QuietExit(3)))
+	tt.MustEqual("", msg)
+	tt.MustEqual(3, code)
+
+	msg, code = FormatError(fmt.Errorf("ctx: %w", ErrWithCode(3, errors.New("boom"))))
+	tt.MustEqual("ctx: boom", msg)
+	tt.MustEqual(3, code)
+
+	msg, code = FormatError(fmt.Errorf("ctx: %w", Errorf(CategoryConfig, "boom").WithHint("yep")))
+	tt.MustEqual("ctx: boom\nhint: yep", msg)
+	tt.MustEqual(ExitConfig, code)
+}
+
+func TestFormatErrorMulti(t *testing.T) {
+	tt := assert.WrapTB(t)
+
+	msg, code := FormatError(testMultiError{errors.New("a"), errors.New("b")})
+	tt.MustEqual("- a\n- b", msg)
+	tt.MustEqual(ExitFailure, code)
+
+	msg, code = FormatError(testMultiError{errors.New("a"), ErrWithCode(3, errors.New("b"))})
+	tt.MustEqual("- a\n- b", msg)
+	tt.MustEqual(3, code)
+}
+
+func TestRunWrappedUsageError(t *testing.T) {
+	tt := assert.WrapTB(t)
+
+	bld := testCmdRunBuilder(func(c Context) error {
+		return fmt.Errorf("loading: %w", UsageErrorf("bad input"))
+	})
+	rn := NewBufferedRunner()
+	err := rn.Run(context.Background(), "test", nil, bld)
+	tt.MustAssert(IsUsageError(err))
+
+	msg, code := FormatError(err)
+	tt.MustEqual(ExitUsage, code)
+	tt.MustAssert(strings.HasPrefix(msg, "Usage: test"), msg)
+	tt.MustAssert(strings.HasSuffix(msg, "\n\nerror: loading: bad input"), msg)
+
+	// Help requests nested in multi-errors are still help requests:
+	bld = testCmdRunBuilder(func(c Context) error {
+		return testMultiError{HelpRequest()}
+	})
+	err = rn.Run(context.Background(), "test", nil, bld)
+	tt.MustAssert(IsHelpRequest(err))
+	tt.MustEqual(0, ErrCode(err))
+}
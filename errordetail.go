@@ -0,0 +1,131 @@
+package cmdy
+
+import (
+	"fmt"
+	"sync"
+)
+
+// ErrorCategory classifies a DetailedError. Each category is the exit code
+// that is used for errors in that category.
+type ErrorCategory int
+
+const (
+	CategoryFailure     ErrorCategory = ExitFailure
+	CategoryData        ErrorCategory = ExitDataError
+	CategoryNoInput     ErrorCategory = ExitNoInput
+	CategoryNoUser      ErrorCategory = ExitNoUser
+	CategoryNoHost      ErrorCategory = ExitNoHost
+	CategoryUnavailable ErrorCategory = ExitUnavailable
+	CategorySoftware    ErrorCategory = ExitSoftware
+	CategoryOS          ErrorCategory = ExitOSError
+	CategoryOSFile      ErrorCategory = ExitOSFile
+	CategoryCantCreate  ErrorCategory = ExitCantCreate
+	CategoryIO          ErrorCategory = ExitIOError
+	CategoryTemporary   ErrorCategory = ExitTempFail
+	CategoryProtocol    ErrorCategory = ExitProtocol
+	CategoryPermission  ErrorCategory = ExitNoPermission
+	CategoryConfig      ErrorCategory = ExitConfig
+)
+
+// Code returns the exit code for the category. The zero value is treated as
+// CategoryFailure.
+func (c ErrorCategory) Code() int {
+	if c == 0 {
+		return ExitFailure
+	}
+	return int(c)
+}
+
+// DetailedError is an error with a category, which determines its exit code,
+// and optional user-facing hint and documentation reference, which are shown
+// by FormatError below the message:
+//
+//	return cmdy.Errorf(cmdy.CategoryCantCreate, "output file %q exists", name).
+//		WithHint("pass -force to overwrite it").
+//		WithDoc("https://example.com/docs/output")
+//
+// DetailedErrors do not print the command's usage; use UsageError for that.
+type DetailedError struct {
+	Err      error
+	Category ErrorCategory
+
+	// Hint suggests how the user might fix the problem, e.g. "try -force".
+	Hint string
+
+	// Doc refers the user to documentation about the error, e.g. a URL or a
+	// help topic.
+	Doc string
+}
+
+// NewError wraps err in a DetailedError in the given category.
+func NewError(category ErrorCategory, err error) *DetailedError {
+	return &DetailedError{Err: err, Category: category}
+}
+
+// Errorf formats a DetailedError in the given category.
+func Errorf(category ErrorCategory, format string, args ...interface{}) *DetailedError {
+	return &DetailedError{Err: fmt.Errorf(format, args...), Category: category}
+}
+
+// WithHint sets the error's Hint and returns the error.
+func (e *DetailedError) WithHint(hint string) *DetailedError {
+	e.Hint = hint
+	return e
+}
+
+// WithHintf formats the error's Hint and returns the error.
+func (e *DetailedError) WithHintf(format string, args ...interface{}) *DetailedError {
+	e.Hint = fmt.Sprintf(format, args...)
+	return e
+}
+
+// WithDoc sets the error's Doc and returns the error.
+func (e *DetailedError) WithDoc(doc string) *DetailedError {
+	e.Doc = doc
+	return e
+}
+
+func (e *DetailedError) Code() int     { return e.Category.Code() }
+func (e *DetailedError) Unwrap() error { return e.Err }
+func (e *DetailedError) Error() string { return e.Err.Error() }
+
+var (
+	exitCodesMu sync.RWMutex
+	exitCodes   = map[int]string{
+		ExitSuccess:      "Success",
+		ExitFailure:      "General failure",
+		ExitUsage:        "Command line usage error",
+		ExitDataError:    "Input data was incorrect",
+		ExitNoInput:      "An input file did not exist or was not readable",
+		ExitNoUser:       "User does not exist",
+		ExitNoHost:       "Host does not exist",
+		ExitUnavailable:  "A service is unavailable",
+		ExitSoftware:     "Internal software error",
+		ExitOSError:      "Operating system error",
+		ExitOSFile:       "A system file was missing or invalid",
+		ExitCantCreate:   "An output file could not be created",
+		ExitIOError:      "An error occurred while doing I/O",
+		ExitTempFail:     "Temporary failure; try again later",
+		ExitProtocol:     "Remote system returned a protocol error",
+		ExitNoPermission: "Permission denied",
+		ExitConfig:       "Configuration error",
+		ExitInternal:     "Internal error",
+	}
+)
+
+// RegisterExitCode sets the description of an exit code, which is shown in
+// the help of commands that list it in Help.ExitCodes. It replaces any
+// existing description, including the ones cmdy provides.
+func RegisterExitCode(code int, description string) {
+	exitCodesMu.Lock()
+	defer exitCodesMu.Unlock()
+	exitCodes[code] = description
+}
+
+// ExitCodeDescription returns the description of an exit code registered
+// using RegisterExitCode, or an empty string if there isn't one.
+func ExitCodeDescription(code int) string {
+	exitCodesMu.RLock()
+	defer exitCodesMu.RUnlock()
+	return exitCodes[code]
+}
@@ -0,0 +1,120 @@
+package cmdy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/shabbyrobe/cmdy/internal/assert"
+)
+
+func TestDetailedErrorFormat(t *testing.T) {
+	tt := assert.WrapTB(t)
+
+	err := Errorf(CategoryCantCreate, "output file %q exists", "out.txt").
+		WithHintf("pass %s to overwrite it", "-force").
+		WithDoc("https://example.com/docs/output")
+
+	msg, code := FormatError(err)
+	tt.MustEqual(ExitCantCreate, code)
+	tt.MustEqual(""+
+		"output file \"out.txt\" exists\n"+
+		"hint: pass -force to overwrite it\n"+
+		"see: https://example.com/docs/output", msg)
+}
+
+func TestDetailedErrorHintNotFormatted(t *testing.T) {
+	tt := assert.WrapTB(t)
+	err := Errorf(CategoryData, "bad input").WithHint("use a value under 100%")
+	msg, _ := FormatError(err)
+	tt.MustEqual("bad input\nhint: use a value under 100%", msg)
+}
+
+func TestDetailedErrorRunnerMessages(t *testing.T) {
+	tt := assert.WrapTB(t)
+
+	msgs := *defaultMessages
+	msgs.ErrorHint = "astuce : %[1]s"
+	msgs.ErrorDoc = "voir : %[1]s"
+	rn := NewBufferedRunner()
+	rn.Messages = &msgs
+
+	err := Errorf(CategoryConfig, "boom").WithHint("yep").WithDoc("doc")
+	msg, code := rn.FormatError(err)
+	tt.MustEqual(ExitConfig, code)
+	tt.MustEqual("boom\nastuce : yep\nvoir : doc", msg)
+
+	tt.MustEqual(ExitConfig, rn.printError(err))
+	tt.MustEqual("boom\nastuce : yep\nvoir : doc\n", rn.StderrBuffer.String())
+}
+
+func TestDetailedErrorDefaults(t *testing.T) {
+	tt := assert.WrapTB(t)
+
+	cause := os.ErrNotExist
+	err := NewError(0, cause)
+	tt.MustAssert(errors.Is(err, cause))
+
+	msg, code := FormatError(err)
+	tt.MustEqual(ExitFailure, code)
+	tt.MustEqual(cause.Error(), msg)
+}
+
+func TestDetailedErrorJSON(t *testing.T) {
+	tt := assert.WrapTB(t)
+
+	err := NewError(CategoryConfig, errors.New("bad config")).WithHint("check the file")
+	rep := NewErrorReport(err, nil)
+	tt.MustEqual(ErrorReport{
+		Code:    ExitConfig,
+		Kind:    ErrorKindError,
+		Message: "bad config",
+		Hint:    "check the file",
+	}, *rep)
+}
+
+func TestHelpExitCodes(t *testing.T) {
+	tt := assert.WrapTB(t)
+
+	RegisterExitCode(3, "Nothing to do")
+	defer RegisterExitCode(3, "")
+
+	cmd := &testCmd{}
+	bld := func() Command {
+		return &helpOverrideCommand{cmd, Help{
+			Synopsis:  "test",
+			ExitCodes: []int{ExitSuccess, 3, ExitNoInput},
+		}}
+	}
+
+	rn := NewBufferedRunner()
+	err := rn.Run(context.Background(), "test", []string{"-help"}, bld)
+	msg, _ := FormatError(err)
+	tt.MustAssert(strings.HasSuffix(msg, ""+
+		"Exit codes:\n"+
+		"    0   Success\n"+
+		"    3   Nothing to do\n"+
+		"    66  An input file did not exist or was not readable"), msg)
+}
+
+type helpOverrideCommand struct {
+	Command
+	help Help
+}
+
+func (h *helpOverrideCommand) Help() Help { return h.help }
+
+func ExampleDetailedError() {
+	err := Errorf(CategoryNoInput, "no such file %q", "in.txt").WithHint("did you mean 'in.csv'?")
+	msg, code := FormatError(err)
+	fmt.Println(code)
+	fmt.Println(msg)
+
+	// Output:
+	// 66
+	// no such file "in.txt"
+	// hint: did you mean 'in.csv'?
+}
@@ -0,0 +1,186 @@
+package cmdy
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// ErrorFormatEnv is the name of an environment variable that can be used to
+// override Runner.ErrorFormat at runtime. Valid values are "text" and "json".
+const ErrorFormatEnv = "CMDY_ERROR_FORMAT"
+
+// ErrorFormat controls how Runner.Fatal prints errors.
+type ErrorFormat int
+
+const (
+	// ErrorFormatText prints the message returned by FormatError.
+	ErrorFormatText ErrorFormat = iota
+
+	// ErrorFormatJSON prints an ErrorReport as a single line of JSON, so
+	// wrapper scripts can parse failures reliably. Help is never paged.
+	ErrorFormatJSON
+)
+
+func parseErrorFormat(s string) (format ErrorFormat, ok bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "text":
+		return ErrorFormatText, true
+	case "json":
+		return ErrorFormatJSON, true
+	default:
+		return ErrorFormatText, false
+	}
+}
+
+func (r *Runner) errorFormat() ErrorFormat {
+	format := r.ErrorFormat
+	if env, ok := os.LookupEnv(ErrorFormatEnv); ok {
+		if f, ok := parseErrorFormat(env); ok {
+			format = f
+		}
+	}
+	return format
+}
+
+// Values for ErrorReport.Kind:
+const (
+	ErrorKindUsage    = "usage"
+	ErrorKindHelp     = "help"
+	ErrorKindInternal = "internal"
+	ErrorKindQuiet    = "quiet"
+	ErrorKindGroup    = "group"
+	ErrorKindError    = "error"
+)
+
+// ErrorReport is the machine-readable form of an error printed by
+// Runner.Fatal when ErrorFormatJSON is used.
+type ErrorReport struct {
+	// Exit code, as returned by FormatError.
+	Code int `json:"code"`
+
+	// One of the ErrorKind constants.
+	Kind string `json:"kind"`
+
+	// Message is the text of the error, without the command's usage. It is
+	// empty for ErrorKindQuiet.
+	Message string `json:"message"`
+
+	// Path to the command that returned the error, if known.
+	Path []string `json:"path,omitempty"`
+
+	// The message of each error in an error group (see FormatError).
+	Errors []string `json:"errors,omitempty"`
+
+	// The command's help, for ErrorKindUsage and ErrorKindHelp.
+	Usage string `json:"usage,omitempty"`
+
+	// Hint and Doc from a DetailedError.
+	Hint string `json:"hint,omitempty"`
+	Doc  string `json:"doc,omitempty"`
+}
+
+// NewErrorReport builds an ErrorReport for err. path may be nil.
+func NewErrorReport(err error, path []string) *ErrorReport {
+	_, code := FormatError(err)
+	rep := &ErrorReport{Code: code, Kind: ErrorKindError, Path: path}
+
+	perr, _ := findError(err, isPanicError).(*PanicError)
+
+	switch uerr := findUsageError(err); {
+	case isQuietError(err):
+		rep.Kind = ErrorKindQuiet
+		return rep
+
+	case uerr != nil:
+		rep.Kind = ErrorKindUsage
+		if uerr.helpRequest {
+			rep.Kind = ErrorKindHelp
+		}
+		rep.Usage = strings.TrimSpace(uerr.usage)
+
+	case perr != nil:
+		rep.Kind = ErrorKindInternal
+		if rep.Path == nil {
+			rep.Path = perr.Path.Names()
+		}
+
+	case code == ExitInternal:
+		rep.Kind = ErrorKindInternal
+	}
+
+	if derr, ok := findError(err, isDetailedError).(*DetailedError); ok {
+		rep.Hint, rep.Doc = derr.Hint, derr.Doc
+	}
+
+	if errs := groupErrors(err); errs != nil {
+		rep.Kind = ErrorKindGroup
+		for _, e := range errs {
+			if !isQuietError(e) {
+				rep.Errors = append(rep.Errors, e.Error())
+			}
+		}
+	}
+
+	rep.Message = err.Error()
+	return rep
+}
+
+// failedRuns remembers the path to the innermost command that returned each
+// of the most recent errors returned by Runner.Run, so that Fatal can report
+// it without changing the error returned by Run. Errors are matched by
+// identity, so concurrent calls to Run do not mix up their paths. It is kept
+// at package level as Runners are copied by value.
+var failedRuns struct {
+	sync.Mutex
+	list []failedRun
+}
+
+// maxFailedRuns limits how many paths are remembered, so a long-lived program
+// that calls Run repeatedly without calling Fatal does not grow forever.
+const maxFailedRuns = 32
+
+type failedRun struct {
+	err  error
+	path []string
+}
+
+func recordFailedRun(err error, path []string) {
+	// Errors that can not be compared can not be found again:
+	if !reflect.TypeOf(err).Comparable() {
+		return
+	}
+	failedRuns.Lock()
+	defer failedRuns.Unlock()
+	failedRuns.list = append(failedRuns.list, failedRun{err: err, path: path})
+	if over := len(failedRuns.list) - maxFailedRuns; over > 0 {
+		failedRuns.list = append(failedRuns.list[:0], failedRuns.list[over:]...)
+	}
+}
+
+// failurePath returns the path to the command that returned err, if err was
+// recently returned by Runner.Run.
+func failurePath(err error) []string {
+	if err == nil || !reflect.TypeOf(err).Comparable() {
+		return nil
+	}
+	failedRuns.Lock()
+	defer failedRuns.Unlock()
+	for i := len(failedRuns.list) - 1; i >= 0; i-- {
+		if failedRuns.list[i].err == err {
+			return failedRuns.list[i].path
+		}
+	}
+	return nil
+}
+
+func (r *Runner) writeJSONError(err error) error {
+	bts, jerr := json.Marshal(NewErrorReport(err, failurePath(err)))
+	if jerr != nil {
+		return jerr
+	}
+	_, werr := r.Stderr.Write(append(bts, '\n'))
+	return werr
+}
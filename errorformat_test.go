@@ -0,0 +1,133 @@
+package cmdy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/shabbyrobe/cmdy/arg"
+	"github.com/shabbyrobe/cmdy/internal/assert"
+)
+
+type testErrorGroup []error
+
+func (g testErrorGroup) Error() string   { return "many errors" }
+func (g testErrorGroup) Errors() []error { return g }
+
+func runJSONError(tt assert.T, args []string, run func(c Context) error) (rep ErrorReport, code int) {
+	tt.Helper()
+
+	var foo int
+	bld := func() Command {
+		return NewGroup("grp", Builders{
+			"sub": (&testCmd{
+				configure: func(flags *FlagSet, args *arg.ArgSet) { flags.IntVar(&foo, "foo", 0, "Foo") },
+				run:       run,
+			}).AsBuilder(),
+		})
+	}
+
+	rn := NewBufferedRunner()
+	rn.ErrorFormat = ErrorFormatJSON
+	err := rn.Run(context.Background(), "test", args, bld)
+	code = rn.printError(err)
+	tt.MustOK(json.Unmarshal(rn.StderrBuffer.Bytes(), &rep))
+	return rep, code
+}
+
+func TestErrorFormatJSON(t *testing.T) {
+	tt := assert.WrapTB(t)
+
+	rep, code := runJSONError(tt, []string{"sub"}, func(c Context) error { return errors.New("boom") })
+	tt.MustEqual(ExitFailure, code)
+	tt.MustEqual(ErrorReport{Code: ExitFailure, Kind: ErrorKindError, Message: "boom", Path: []string{"test", "sub"}}, rep)
+
+	rep, code = runJSONError(tt, []string{"sub"}, func(c Context) error { return QuietExit(3) })
+	tt.MustEqual(3, code)
+	tt.MustEqual(ErrorReport{Code: 3, Kind: ErrorKindQuiet, Path: []string{"test", "sub"}}, rep)
+
+	rep, _ = runJSONError(tt, []string{"sub"}, func(c Context) error {
+		return testErrorGroup{errors.New("a"), errors.New("b")}
+	})
+	tt.MustEqual(ErrorKindGroup, rep.Kind)
+	tt.MustEqual([]string{"a", "b"}, rep.Errors)
+
+	rep, _ = runJSONError(tt, []string{"sub"}, func(c Context) error { return ErrWithCode(ExitInternal, errors.New("bad")) })
+	tt.MustEqual(ErrorKindInternal, rep.Kind)
+	tt.MustEqual(ExitInternal, rep.Code)
+}
+
+func TestErrorFormatJSONUsage(t *testing.T) {
+	tt := assert.WrapTB(t)
+
+	rep, code := runJSONError(tt, []string{"sub", "-foo=x"}, nil)
+	tt.MustEqual(ExitUsage, code)
+	tt.MustEqual(ErrorKindUsage, rep.Kind)
+	tt.MustEqual([]string{"test", "sub"}, rep.Path)
+	tt.MustEqual(`invalid value "x" for flag -foo: parse error`, rep.Message)
+	tt.MustEqual("Usage: test sub [-foo=<int>] \n\nFlags:\n  -foo=<int>\n        Foo", rep.Usage)
+
+	rep, code = runJSONError(tt, []string{"-help"}, nil)
+	tt.MustEqual(0, code)
+	tt.MustEqual(ErrorKindHelp, rep.Kind)
+	tt.MustEqual([]string{"test"}, rep.Path)
+}
+
+func TestErrorFormatJSONConcurrentPaths(t *testing.T) {
+	tt := assert.WrapTB(t)
+
+	bld := func() Command {
+		return NewGroup("grp", Builders{
+			"a": testCmdRunBuilder(func(c Context) error { return errors.New("a") }),
+			"b": testCmdRunBuilder(func(c Context) error { return errors.New("b") }),
+		})
+	}
+
+	// A shared Runner must not mix up the paths of concurrent failures:
+	rn := NewBufferedRunner()
+	rn.ErrorFormat = ErrorFormatJSON
+
+	const n = 20
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		name := []string{"a", "b"}[i%2]
+		go func() { errs <- rn.Run(context.Background(), "test", []string{name}, bld) }()
+	}
+	for i := 0; i < n; i++ {
+		err := <-errs
+		tt.MustEqual([]string{"test", err.Error()}, NewErrorReport(err, failurePath(err)).Path)
+	}
+}
+
+func TestErrorFormatJSONPreservesError(t *testing.T) {
+	tt := assert.WrapTB(t)
+	os.Setenv(ErrorFormatEnv, "json")
+	defer os.Unsetenv(ErrorFormatEnv)
+
+	// The error returned by Run does not depend on the ErrorFormat:
+	boom := errors.New("boom")
+	rn := NewBufferedRunner()
+	err := rn.Run(context.Background(), "test", nil, testCmdRunBuilder(func(c Context) error { return boom }))
+	tt.MustAssert(err == boom, err)
+
+	tt.MustEqual(ExitFailure, rn.printError(err))
+	tt.MustEqual(`{"code":1,"kind":"error","message":"boom","path":["test"]}`+"\n", rn.StderrBuffer.String())
+}
+
+func TestErrorFormatEnv(t *testing.T) {
+	tt := assert.WrapTB(t)
+	os.Setenv(ErrorFormatEnv, "json")
+	defer os.Unsetenv(ErrorFormatEnv)
+
+	rn := NewBufferedRunner()
+	tt.MustEqual(ExitFailure, rn.printError(errors.New("boom")))
+	tt.MustEqual(`{"code":1,"kind":"error","message":"boom"}`+"\n", rn.StderrBuffer.String())
+
+	os.Setenv(ErrorFormatEnv, "text")
+	rn = NewBufferedRunner()
+	rn.ErrorFormat = ErrorFormatJSON
+	rn.printError(errors.New("boom"))
+	tt.MustEqual("boom\n", rn.StderrBuffer.String())
+}
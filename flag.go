@@ -21,6 +21,11 @@ type FlagSet struct {
 	*flag.FlagSet
 	WrapWidth int
 	hideUsage bool
+
+	// Names passed to MarkInherited, and flags copied from a parent FlagSet
+	// by Runner.Run. Copied flags are inherited in turn.
+	inherited  []string
+	fromParent map[string]bool
 }
 
 func NewFlagSet() *FlagSet {
@@ -47,6 +52,61 @@ func FlagSetFromStd(fs *flag.FlagSet) *FlagSet {
 // HideUsage prevents the "Flags" section from appearing in the Usage string.
 func (fs *FlagSet) HideUsage() { fs.hideUsage = true }
 
+// MarkInherited marks flags as inherited by every subcommand run below the
+// command that owns the FlagSet, at any depth. Inherited flags are accepted
+// before or after the subcommand's name, so if '-v' is marked as inherited by
+// 'tool', 'tool -v sub cmd' and 'tool sub cmd -v' are equivalent.
+//
+// The flag.Value is shared with each subcommand, so it has been set by the
+// time the innermost Command's Run method is called no matter where it
+// appeared. A Group's Before hook is called before its subcommand's flags are
+// parsed, so it only sees values passed to the Group.
+//
+// If a subcommand defines a flag with the same name, its own flag is used.
+// Inherited flags are listed under "Global flags" in the subcommand's help.
+//
+// MarkInherited panics if a flag has not been defined.
+func (fs *FlagSet) MarkInherited(names ...string) {
+	for _, name := range names {
+		if fs.Lookup(name) == nil {
+			panic(fmt.Errorf("cmdy: inherited flag -%s not defined", name))
+		}
+		fs.inherited = append(fs.inherited, name)
+	}
+}
+
+// inherit copies the flags marked as inherited in parent, unless fs already
+// defines a flag with the same name.
+func (fs *FlagSet) inherit(parent *FlagSet) {
+	for _, name := range parent.inherited {
+		if fs.Lookup(name) != nil {
+			continue
+		}
+		pf := parent.Lookup(name)
+		fs.Var(pf.Value, name, pf.Usage)
+
+		// Var uses the current value as the default, which may already have
+		// been set by the parent:
+		fs.Lookup(name).DefValue = pf.DefValue
+
+		if fs.fromParent == nil {
+			fs.fromParent = make(map[string]bool)
+		}
+		fs.fromParent[name] = true
+		fs.inherited = append(fs.inherited, name)
+	}
+}
+
+// inheritPath inherits flags from each command in parents that has a FlagSet,
+// starting with the innermost.
+func (fs *FlagSet) inheritPath(parents CommandPath) {
+	for i := len(parents) - 1; i >= 0; i-- {
+		if parent := parents[i].Flags; parent != nil {
+			fs.inherit(parent)
+		}
+	}
+}
+
 // Invocation string for the flags, for example '[-foo=<yep>] [-bar=<pants>]`.
 // If there are too many flags, `[options]` is returned instead.
 func (fs *FlagSet) Invocation() string {
@@ -78,15 +138,32 @@ func (fs *FlagSet) Invocation() string {
 
 // Usage returns the full usage string for the FlagSet, provided HideUsage()
 // has not been set.
+//
+// Flags inherited from a parent command are not included; see GlobalUsage.
 func (fs *FlagSet) Usage() string {
+	return fs.usage(false)
+}
+
+// GlobalUsage returns the usage string for the flags inherited from parent
+// commands, provided HideUsage() has not been set. See MarkInherited.
+func (fs *FlagSet) GlobalUsage() string {
+	return fs.usage(true)
+}
+
+func (fs *FlagSet) usage(global bool) string {
 	if fs.hideUsage {
 		return ""
 	}
 
 	var usables = make([]usage.Usable, 0, fs.NFlag())
 	fs.VisitAll(func(f *flag.Flag) {
-		usables = append(usables, usableFlag{f})
+		if fs.fromParent[f.Name] == global {
+			usables = append(usables, usableFlag{f})
+		}
 	})
+	if len(usables) == 0 {
+		return ""
+	}
 	return usage.Usage(fs.WrapWidth, usables...)
 }
 
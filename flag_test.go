@@ -1,10 +1,13 @@
 package cmdy
 
 import (
+	"context"
+	"fmt"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/shabbyrobe/cmdy/arg"
 	"github.com/shabbyrobe/cmdy/internal/assert"
 )
 
@@ -156,3 +159,93 @@ func TestFlagVarHintable(t *testing.T) {
 	// FIXME: brittle test, but adequate for now.
 	tt.MustEqual(expectedHintableUsage, "\n"+fs.Usage())
 }
+
+func TestFlagInherited(t *testing.T) {
+	var verbose bool
+	var config string
+	var seenConfig string
+
+	bld := func() Command {
+		verbose, config, seenConfig = false, "", ""
+		return NewGroup("tool", Builders{
+			"sub": func() Command {
+				return NewGroup("sub", Builders{
+					"cmd": testCmdRunBuilder(func(ctx Context) error {
+						seenConfig = ctx.Stack().Flag("config").Value.String()
+						return nil
+					}),
+				})
+			},
+		}, GroupFlags(func() *FlagSet {
+			fs := NewFlagSet()
+			fs.BoolVar(&verbose, "v", false, "Verbose")
+			fs.StringVar(&config, "config", "", "Config file")
+			fs.MarkInherited("v")
+			return fs
+		}))
+	}
+
+	for idx, args := range [][]string{
+		{"-v", "-config=yep", "sub", "cmd"},
+		{"-config=yep", "sub", "-v", "cmd"},
+		{"-config=yep", "sub", "cmd", "-v"},
+	} {
+		t.Run(fmt.Sprint(idx), func(t *testing.T) {
+			tt := assert.WrapTB(t)
+			rn := NewBufferedRunner()
+			tt.MustOK(rn.Run(context.Background(), "tool", args, bld))
+			tt.MustAssert(verbose)
+			tt.MustEqual("yep", seenConfig)
+		})
+	}
+
+	t.Run("notinherited", func(t *testing.T) {
+		tt := assert.WrapTB(t)
+		rn := NewBufferedRunner()
+		err := rn.Run(context.Background(), "tool", []string{"sub", "cmd", "-config=yep"}, bld)
+		tt.MustAssert(IsUsageError(err))
+	})
+
+	t.Run("help", func(t *testing.T) {
+		tt := assert.WrapTB(t)
+		rn := NewBufferedRunner()
+		err := rn.Run(context.Background(), "tool", []string{"-v", "sub", "cmd", "-help"}, bld)
+		tt.MustAssert(IsHelpRequest(err))
+
+		msg, _ := FormatError(err)
+		tt.MustAssert(strings.Contains(msg, "Global flags:\n  -v    Verbose"), msg)
+		tt.MustAssert(!strings.Contains(msg, "Flags:\n"), msg)
+	})
+}
+
+func TestFlagInheritedOverridden(t *testing.T) {
+	tt := assert.WrapTB(t)
+
+	var parentV, childV string
+	bld := func() Command {
+		return NewGroup("tool", Builders{
+			"cmd": (&testCmd{configure: func(flags *FlagSet, args *arg.ArgSet) {
+				flags.StringVar(&childV, "v", "", "Child")
+			}}).AsBuilder(),
+		}, GroupFlags(func() *FlagSet {
+			fs := NewFlagSet()
+			fs.StringVar(&parentV, "v", "", "Parent")
+			fs.MarkInherited("v")
+			return fs
+		}))
+	}
+
+	rn := NewBufferedRunner()
+	tt.MustOK(rn.Run(context.Background(), "tool", []string{"-v=a", "cmd", "-v=b"}, bld))
+	tt.MustEqual("a", parentV)
+	tt.MustEqual("b", childV)
+}
+
+func TestFlagMarkInheritedUndefinedPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic")
+		}
+	}()
+	NewFlagSet().MarkInherited("nope")
+}
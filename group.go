@@ -29,8 +29,19 @@ func GroupMatcher(cm Matcher) GroupOption { return func(grp *Group) { grp.Matche
 
 // GroupPrefixMatcher assigns the PrefixMatcher of the specified minimum length
 // to the Group's Matcher.
+//
+// The PrefixMatcher is created when it is used rather than when the option is
+// applied, so builders added by other options (for example GroupHelpCommand)
+// can be matched regardless of the order the options are passed in.
 func GroupPrefixMatcher(minLen int) GroupOption {
-	return func(grp *Group) { grp.Matcher = PrefixMatcher(grp, minLen) }
+	if minLen <= 0 {
+		panic("minLen must be > 0")
+	}
+	return func(grp *Group) {
+		grp.Matcher = func(bldrs Builders, in string) (bld Builder, name string, rerr error) {
+			return PrefixMatcher(grp, minLen)(bldrs, in)
+		}
+	}
 }
 
 // GroupUsage sets the Usage string in the Group's Help. The result of this function may
@@ -150,6 +161,9 @@ type Group struct {
 
 	help   Help
 	hidden map[string]bool
+	topics HelpTopics
+
+	hasHelpCommand bool
 
 	state GroupRunState
 }
@@ -170,11 +184,16 @@ func NewGroup(synopsis string, builders Builders, opts ...GroupOption) *Group {
 func (grp *Group) Help() Help { return grp.help }
 
 func (grp *Group) BuildHelp(into *strings.Builder) error {
-	into.WriteString("Commands:\n")
+	return grp.buildHelp(into, defaultMessages)
+}
+
+func (grp *Group) buildHelp(into *strings.Builder, msgs *Messages) error {
+	into.WriteString(msgs.Commands)
+	into.WriteByte('\n')
 	names := make([]string, 0, len(grp.Builders))
 	width := 6
 	for name := range grp.Builders {
-		ln := len(name)
+		ln := wrap.StringWidth(name)
 		if ln > width {
 			width = ln
 		}
@@ -182,6 +201,11 @@ func (grp *Group) BuildHelp(into *strings.Builder) error {
 			names = append(names, name)
 		}
 	}
+	for name := range grp.topics {
+		if ln := wrap.StringWidth(name); ln > width {
+			width = ln
+		}
+	}
 	sort.Strings(names)
 
 	const cmdNameIndent = 4
@@ -195,10 +219,30 @@ func (grp *Group) BuildHelp(into *strings.Builder) error {
 	wrp := wrap.Wrapper{Indent: string(indent)}
 
 	for _, l := range names {
-		s := grp.Builders[l]()
-		syn := s.Help().Synopsis
+		var syn string
+		if l == HelpCommandName && grp.hasHelpCommand {
+			syn = msgs.HelpCommandSynopsis
+		} else {
+			syn = grp.Builders[l]().Help().Synopsis
+		}
 		syn = wrp.Wrap(syn)
-		fmt.Fprintf(into, "    %-*s  %s\n", width, l, syn)
+		fmt.Fprintf(into, "    %s  %s\n", wrap.PadRight(l, width), syn)
+	}
+
+	if len(grp.topics) > 0 {
+		topics := make([]string, 0, len(grp.topics))
+		for name := range grp.topics {
+			topics = append(topics, name)
+		}
+		sort.Strings(topics)
+
+		into.WriteByte('\n')
+		into.WriteString(msgs.HelpTopics)
+		into.WriteByte('\n')
+		for _, l := range topics {
+			syn := wrp.Wrap(grp.topics[l].Synopsis)
+			fmt.Fprintf(into, "    %s  %s\n", wrap.PadRight(l, width), syn)
+		}
 	}
 
 	return nil
@@ -238,7 +282,8 @@ func (grp *Group) Run(ctx Context) error {
 
 	if grp.state.Builder == nil {
 		if grp.state.Subcommand != "" {
-			return UsageError(fmt.Errorf("unknown command %q", grp.state.Subcommand))
+			msgs := ctx.Runner().messages()
+			return UsageError(fmt.Errorf(msgs.UnknownCommand, grp.state.Subcommand))
 		} else {
 			return UsageError(nil)
 		}
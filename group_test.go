@@ -155,3 +155,21 @@ func TestGroup_Hide(t *testing.T) {
 	tt.MustAssert(strings.Contains(out, "GM68tb0F"))
 	tt.MustAssert(strings.Contains(out, "4GKwDcbp"))
 }
+
+func TestGroup_BuildHelpWideNames(t *testing.T) {
+	tt := assert.WrapTB(t)
+
+	grp := NewGroup("set",
+		Builders{
+			"日本語":      func() Command { return &testCmd{synopsis: "wide"} },
+			"abcdefgh": func() Command { return &testCmd{synopsis: "narrow"} },
+		},
+	)
+
+	var bld strings.Builder
+	tt.MustOK(grp.BuildHelp(&bld))
+	lines := strings.Split(strings.TrimSpace(bld.String()), "\n")
+	tt.MustEqual(3, len(lines))
+	tt.MustEqual("    abcdefgh  narrow", lines[1])
+	tt.MustEqual("    日本語    wide", lines[2])
+}
@@ -2,9 +2,11 @@ package cmdy
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/shabbyrobe/cmdy/arg"
+	"github.com/shabbyrobe/cmdy/cmdstr"
 	"github.com/shabbyrobe/cmdy/internal/wrap"
 )
 
@@ -33,6 +35,11 @@ type Help struct {
 	Usage string
 
 	Examples Examples
+
+	// ExitCodes lists the exit codes the command can return, which are shown
+	// in the help message with the descriptions registered using
+	// RegisterExitCode.
+	ExitCodes []int
 }
 
 type Examples []Example
@@ -74,6 +81,9 @@ with the 'cmd' subcommand is this:
 
 If you are using nested commands dynamically, rather than via cmdy.Group,
 the subcommand path may be missing from the start of the invocation.
+
+Stderr, Env, Files, Dir and OutputFiles are only used when the example is
+tested (see cmdytest.ExampleTester), and never appear in any help message.
 */
 type Example struct {
 	Desc    string
@@ -91,6 +101,9 @@ type Example struct {
 	Input  string
 	Output string
 
+	// Expected output to stderr.
+	Stderr string
+
 	// If true, the output is always hidden.
 	HideOutput bool
 
@@ -98,6 +111,43 @@ type Example struct {
 	TestOnly bool
 
 	TestMode ExampleTestMode
+
+	// OutputMode controls how Output and Stderr are compared against what
+	// the command actually wrote when the example is tested using ExampleRun.
+	// The default, ExampleOutputIgnore, does not check them, so Output can be
+	// used purely as an illustration. If Output or Stderr is empty, it is not
+	// checked. Output is not shown in help messages if OutputMode is
+	// ExampleOutputRegexp.
+	OutputMode ExampleOutputMode
+
+	// Env contains environment variables in "KEY=value" form that are set
+	// while the example is tested. Values may refer to the temporary
+	// directory that contains Files using $WORK. No other variables are
+	// expanded, and '$$' is a literal '$'.
+	Env []string
+
+	// Files contains fixture files to create before the example is tested,
+	// mapping slash-separated paths to file contents. Paths are relative to a
+	// temporary directory which is removed after the test.
+	Files map[string]string
+
+	// Dir is the working directory the example is tested in, relative to the
+	// temporary directory that contains Files. It is created if it does not
+	// exist.
+	Dir string
+
+	// OutputFiles contains the expected contents of files written by the
+	// command, using the same paths as Files. They are always checked when
+	// the example is tested using ExampleRun: they are compared according to
+	// OutputMode, or using ExampleOutputTrimmed if OutputMode is
+	// ExampleOutputIgnore.
+	OutputFiles map[string]string
+}
+
+// NeedsSandbox returns true if the example uses any of the fields which require
+// a temporary working directory when it is tested.
+func (e Example) NeedsSandbox() bool {
+	return len(e.Env) > 0 || len(e.Files) > 0 || e.Dir != "" || len(e.OutputFiles) > 0
 }
 
 type ExampleTestMode int
@@ -107,7 +157,44 @@ const (
 	ExampleRun       ExampleTestMode = 1
 )
 
+type ExampleOutputMode int
+
+const (
+	// Output is never checked. This is the default, as the Output of most
+	// examples is an illustration rather than exactly what the command
+	// writes.
+	ExampleOutputIgnore ExampleOutputMode = 0
+
+	// Output must match after leading and trailing whitespace is trimmed
+	// from both the expected and actual output.
+	ExampleOutputTrimmed ExampleOutputMode = 1
+
+	// Output must match exactly.
+	ExampleOutputExact ExampleOutputMode = 2
+
+	// Output is a regular expression (regexp package syntax) that must
+	// match the actual output. Use '^' and '$' to anchor it.
+	ExampleOutputRegexp ExampleOutputMode = 3
+
+	// Actual output must start with Output.
+	ExampleOutputPrefix ExampleOutputMode = 4
+
+	// Actual output must contain Output.
+	ExampleOutputContains ExampleOutputMode = 5
+)
+
+// commandHelp builds the help message for cmd exactly as it would appear if
+// cmd were invoked with the '-help' flag at the end of path.
+func commandHelp(msgs *Messages, cmd Command, path CommandPath) (string, error) {
+	flagSet, argSet := configure(cmd)
+	if len(path) > 0 {
+		flagSet.inheritPath(path[:len(path)-1])
+	}
+	return buildHelp(msgs, cmd, path, flagSet, argSet)
+}
+
 func buildHelp(
+	msgs *Messages,
 	cmd Command,
 	path CommandPath,
 	flagSet *FlagSet,
@@ -117,12 +204,14 @@ func buildHelp(
 
 	sections := []HelpSection{
 		synopsisSection{&help},
-		invocationSection{path, flagSet, argSet},
+		invocationSection{msgs, path, flagSet, argSet},
 		usageSection{&help},
-		flagSection{flagSet},
-		argSection{argSet},
-		exampleSection{help.Examples, path},
-		commandSection{cmd},
+		flagSection{msgs, flagSet},
+		globalFlagSection{msgs, flagSet},
+		argSection{msgs, argSet},
+		exampleSection{msgs, help.Examples, path},
+		exitCodeSection{msgs, help.ExitCodes},
+		commandSection{msgs, cmd},
 	}
 
 	var out strings.Builder
@@ -148,6 +237,12 @@ type HelpSection interface {
 	BuildHelp(into *strings.Builder) error
 }
 
+// messagesHelpSection is implemented by HelpSections that contain text that
+// can be translated using Messages.
+type messagesHelpSection interface {
+	buildHelp(into *strings.Builder, msgs *Messages) error
+}
+
 type synopsisSection struct {
 	help *Help
 }
@@ -163,13 +258,14 @@ func (s synopsisSection) BuildHelp(into *strings.Builder) error {
 }
 
 type invocationSection struct {
+	msgs    *Messages
 	path    CommandPath
 	flagSet *FlagSet
 	argSet  *arg.ArgSet
 }
 
 func (i invocationSection) BuildHelp(into *strings.Builder) error {
-	into.WriteString("Usage: ")
+	into.WriteString(i.msgs.Usage)
 
 	for idx, p := range i.path {
 		if idx > 0 {
@@ -208,6 +304,7 @@ func (us usageSection) BuildHelp(into *strings.Builder) error {
 }
 
 type flagSection struct {
+	msgs    *Messages
 	flagSet *FlagSet
 }
 
@@ -215,14 +312,58 @@ func (fs flagSection) BuildHelp(into *strings.Builder) error {
 	if fs.flagSet != nil {
 		fu := fs.flagSet.Usage()
 		if fu != "" {
-			into.WriteString("Flags:\n")
+			into.WriteString(fs.msgs.Flags)
+			into.WriteByte('\n')
 			into.WriteString(fu)
 		}
 	}
 	return nil
 }
 
+type globalFlagSection struct {
+	msgs    *Messages
+	flagSet *FlagSet
+}
+
+func (fs globalFlagSection) BuildHelp(into *strings.Builder) error {
+	if fs.flagSet != nil {
+		fu := fs.flagSet.GlobalUsage()
+		if fu != "" {
+			into.WriteString(fs.msgs.GlobalFlags)
+			into.WriteByte('\n')
+			into.WriteString(fu)
+		}
+	}
+	return nil
+}
+
+type exitCodeSection struct {
+	msgs  *Messages
+	codes []int
+}
+
+func (es exitCodeSection) BuildHelp(into *strings.Builder) error {
+	if len(es.codes) == 0 {
+		return nil
+	}
+
+	width := 0
+	for _, code := range es.codes {
+		if ln := len(strconv.Itoa(code)); ln > width {
+			width = ln
+		}
+	}
+
+	into.WriteString(es.msgs.ExitCodes)
+	into.WriteByte('\n')
+	for _, code := range es.codes {
+		fmt.Fprintf(into, "    %s  %s\n", wrap.PadRight(strconv.Itoa(code), width), ExitCodeDescription(code))
+	}
+	return nil
+}
+
 type argSection struct {
+	msgs   *Messages
 	argSet *arg.ArgSet
 }
 
@@ -230,7 +371,8 @@ func (as argSection) BuildHelp(into *strings.Builder) error {
 	if as.argSet != nil {
 		au := as.argSet.Usage()
 		if au != "" {
-			into.WriteString("Arguments:\n")
+			into.WriteString(as.msgs.Arguments)
+			into.WriteByte('\n')
 			into.WriteString(au)
 		}
 	}
@@ -238,6 +380,7 @@ func (as argSection) BuildHelp(into *strings.Builder) error {
 }
 
 type exampleSection struct {
+	msgs     *Messages
 	examples Examples
 	path     CommandPath
 }
@@ -245,7 +388,8 @@ type exampleSection struct {
 func (es exampleSection) BuildHelp(into *strings.Builder) error {
 	if len(es.examples) > 0 {
 		pathStr := es.path.Invocation()
-		into.WriteString("Examples:\n")
+		into.WriteString(es.msgs.Examples)
+		into.WriteByte('\n')
 		for idx, e := range es.examples {
 			if idx > 0 {
 				into.WriteByte('\n')
@@ -282,9 +426,9 @@ func (es exampleSection) renderExample(into *strings.Builder, e *Example, pathSt
 
 		if e.Input != "" {
 			if len(e.Input) <= maxInHideSize {
-				cmd = fmt.Sprintf("echo %q | %s", e.Input, e.Command)
+				cmd = "echo " + cmdstr.Quote(e.Input) + " | " + cmd
 			} else {
-				cmd = fmt.Sprintf("... | %s", e.Command)
+				cmd = "... | " + cmd
 			}
 		}
 
@@ -298,7 +442,9 @@ func (es exampleSection) renderExample(into *strings.Builder, e *Example, pathSt
 	}
 
 	{ // Output:
-		if !e.HideOutput && e.Output != "" {
+		// Patterns aren't meaningful to users:
+		shown := e.OutputMode != ExampleOutputRegexp
+		if !e.HideOutput && shown && e.Output != "" {
 			lines := strings.SplitN(strings.TrimSpace(e.Output), "\n", maxOutLines+1)
 
 			// replace unsplit remainder with ellipsis
@@ -308,8 +454,8 @@ func (es exampleSection) renderExample(into *strings.Builder, e *Example, pathSt
 
 			for idx, line := range lines {
 				// ensure individual lines are truncated if they exceed the max width:
-				if len(line) >= maxOutWidth {
-					line = strings.TrimSpace(line[:maxOutWidth])
+				if wrap.StringWidth(line) >= maxOutWidth {
+					line = strings.TrimSpace(wrap.Truncate(line, maxOutWidth))
 					if !strings.HasPrefix(line, "...") {
 						line += " ..."
 					}
@@ -324,10 +470,14 @@ func (es exampleSection) renderExample(into *strings.Builder, e *Example, pathSt
 }
 
 type commandSection struct {
-	cmd Command
+	msgs *Messages
+	cmd  Command
 }
 
 func (cs commandSection) BuildHelp(into *strings.Builder) error {
+	if ms, ok := cs.cmd.(messagesHelpSection); ok {
+		return ms.buildHelp(into, cs.msgs)
+	}
 	hs, ok := cs.cmd.(HelpSection)
 	if !ok {
 		return nil
@@ -9,7 +9,7 @@ import (
 
 const exampleRenderResult = `
   # thingo
-  $ echo "borg" | floobfleebflarbflem \
+  $ echo borg | floobfleebflarbflem \
       flubflobfleedfloobfleebflarbflemflubflobfleedfloobfleeb flarb flem flub flob \
       fleed
   it works
@@ -31,3 +31,30 @@ func TestExampleRender(t *testing.T) {
 	es.renderExample(&o, &ex, "")
 	tt.MustEqual(strings.TrimRight(exampleRenderResult[1:], "\n"), strings.TrimRight(o.String(), "\n"))
 }
+
+func TestExampleRenderInputQuoted(t *testing.T) {
+	tt := assert.WrapTB(t)
+	ex := Example{Command: "-flag", Input: "it's $HOME"}
+
+	var o strings.Builder
+	es := exampleSection{}
+	es.renderExample(&o, &ex, "tool sub")
+	tt.MustEqual(`  $ echo "it's \$HOME" | tool sub -flag`, strings.TrimRight(o.String(), "\n"))
+}
+
+func TestExampleRenderOutputMode(t *testing.T) {
+	tt := assert.WrapTB(t)
+	ex := Example{Command: "-flag", Output: "^it works$", OutputMode: ExampleOutputRegexp}
+	var o strings.Builder
+	es := exampleSection{}
+	es.renderExample(&o, &ex, "tool")
+	tt.MustEqual("  $ tool -flag", strings.TrimRight(o.String(), "\n"))
+
+	for _, mode := range []ExampleOutputMode{ExampleOutputIgnore, ExampleOutputPrefix} {
+		ex := Example{Command: "-flag", Output: "it works", OutputMode: mode}
+		var o strings.Builder
+		es := exampleSection{}
+		es.renderExample(&o, &ex, "tool")
+		tt.MustEqual("  $ tool -flag\n  it works", strings.TrimRight(o.String(), "\n"))
+	}
+}
@@ -0,0 +1,153 @@
+package cmdy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/shabbyrobe/cmdy/arg"
+)
+
+// HelpCommandName is the name of the subcommand added to a Group by
+// GroupHelpCommand and GroupHelpTopics.
+const HelpCommandName = "help"
+
+// HelpTopic is a standalone page of help that is not associated with a command,
+// for example a description of the file formats or environment variables your
+// program understands. Topics are listed in the Group's help, and are shown
+// using the Group's help command:
+//
+//	$ tool help formats
+//
+type HelpTopic struct {
+	// Synopsis is shown next to the topic's name in the Group's list of
+	// help topics. It is required.
+	Synopsis string
+
+	// Body contains the full text of the topic.
+	Body string
+}
+
+type HelpTopics map[string]HelpTopic
+
+func (t HelpTopic) render() string {
+	var out strings.Builder
+	out.WriteString(strings.TrimSpace(t.Synopsis))
+	out.WriteByte('\n')
+	if body := strings.TrimSpace(t.Body); body != "" {
+		out.WriteByte('\n')
+		out.WriteString(body)
+		out.WriteByte('\n')
+	}
+	return out.String()
+}
+
+// GroupHelpCommand adds a 'help' subcommand to the Group, which accepts a path
+// to a command through any nested Groups and shows the same help that would
+// be shown if the '-help' flag was passed to that command:
+//
+//	$ tool help sub cmd   // Same as 'tool sub cmd -help'
+//	$ tool help           // Same as 'tool -help'
+//
+// Each name in the path is resolved using the Matcher of the Group it is
+// passed to.
+//
+// If the Group already contains a builder called 'help', GroupHelpCommand
+// will panic.
+func GroupHelpCommand() GroupOption {
+	return func(grp *Group) { grp.addHelpCommand() }
+}
+
+// GroupHelpTopics registers standalone help topics with the Group. Topics are
+// listed in the Group's help and can be shown with the Group's help command,
+// which GroupHelpTopics will add if it is not already present.
+//
+// Builders take precedence over topics of the same name.
+func GroupHelpTopics(topics HelpTopics) GroupOption {
+	return func(grp *Group) {
+		grp.addHelpCommand()
+		if grp.topics == nil {
+			grp.topics = make(HelpTopics, len(topics))
+		}
+		for name, topic := range topics {
+			grp.topics[name] = topic
+		}
+	}
+}
+
+func (grp *Group) addHelpCommand() {
+	if grp.Builders == nil {
+		grp.Builders = Builders{}
+	}
+	if _, ok := grp.Builders[HelpCommandName]; ok {
+		if grp.hasHelpCommand {
+			return
+		}
+		panic(fmt.Errorf("group already contains a builder named %q", HelpCommandName))
+	}
+	grp.hasHelpCommand = true
+	grp.Builders[HelpCommandName] = func() Command {
+		return &helpCommand{grp: grp}
+	}
+}
+
+type helpCommand struct {
+	grp  *Group
+	path []string
+}
+
+func (h *helpCommand) Help() Help {
+	return Synopsis(defaultMessages.HelpCommandSynopsis)
+}
+
+func (h *helpCommand) Configure(flags *FlagSet, args *arg.ArgSet) {
+	args.Remaining(&h.path, "command", arg.AnyLen, "Path to a command, or the name of a help topic")
+}
+
+func (h *helpCommand) Run(ctx Context) error {
+	msgs := ctx.Runner().messages()
+
+	// The help command itself is removed from the path so the help message
+	// appears exactly as it would if the command was invoked with '-help':
+	stack := ctx.Stack()
+	path := make(CommandPath, 0, len(stack)-1+len(h.path))
+	path = append(path, stack[:len(stack)-1]...)
+
+	var cmd Command = h.grp
+	grp := h.grp
+
+	for idx, name := range h.path {
+		if grp == nil {
+			return UsageError(fmt.Errorf(msgs.NoSubcommands, path.Invocation()))
+		}
+
+		bld, match, err := grp.Builder(name)
+		if err != nil {
+			return err
+		}
+
+		if bld == nil {
+			topic, ok := grp.topics[name]
+			if !ok || idx != len(h.path)-1 {
+				return UsageError(fmt.Errorf(msgs.UnknownHelpTopic, name))
+			}
+			return &usageError{helpRequest: true, usage: topic.render(), msgs: msgs}
+		}
+
+		cmd = bld()
+		ref := CommandRef{Name: match, Command: cmd}
+		if idx != len(h.path)-1 {
+			// Commands between the help command and the command whose help
+			// is shown are configured, so their inherited flags are too:
+			ref.Flags, ref.Args = configure(cmd)
+			ref.Flags.inheritPath(path)
+		}
+		path = append(path, ref)
+		grp, _ = cmd.(*Group)
+	}
+
+	usage, err := commandHelp(msgs, cmd, path)
+	if err != nil {
+		return err
+	}
+	return &usageError{helpRequest: true, usage: usage, msgs: msgs}
+}
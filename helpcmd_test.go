@@ -0,0 +1,131 @@
+package cmdy
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/shabbyrobe/cmdy/internal/assert"
+)
+
+func helpTestBuilder() Command {
+	sub := func() Command {
+		return NewGroup("Sub group", Builders{
+			"cmd": func() Command { return &testCmd{synopsis: "Nested command", usage: "Nested usage"} },
+		}, GroupHelpTopics(HelpTopics{
+			"nested": {Synopsis: "Nested topic", Body: "Nested body"},
+		}))
+	}
+
+	return NewGroup("Root group", Builders{
+		"sub":  sub,
+		"leaf": func() Command { return &testCmd{synopsis: "Leaf command"} },
+	},
+		GroupPrefixMatcher(2),
+		GroupHelpTopics(HelpTopics{
+			"formats":     {Synopsis: "Supported formats", Body: "Formats body"},
+			"environment": {Synopsis: "Environment variables"},
+		}),
+	)
+}
+
+func mustHelp(t *testing.T, args ...string) string {
+	t.Helper()
+	tt := assert.WrapTB(t)
+	rn := NewBufferedRunner()
+	err := rn.Run(context.Background(), "tool", args, helpTestBuilder)
+	tt.MustAssert(IsHelpRequest(err), err)
+	msg, code := FormatError(err)
+	tt.MustEqual(0, code)
+	return msg
+}
+
+func TestHelpCommandMatchesHelpFlag(t *testing.T) {
+	tt := assert.WrapTB(t)
+	tt.MustEqual(mustHelp(t, "-help"), mustHelp(t, "help"))
+	tt.MustEqual(mustHelp(t, "sub", "-help"), mustHelp(t, "help", "sub"))
+	tt.MustEqual(mustHelp(t, "sub", "cmd", "-help"), mustHelp(t, "help", "sub", "cmd"))
+	tt.MustEqual(mustHelp(t, "leaf", "-help"), mustHelp(t, "help", "leaf"))
+
+	out := mustHelp(t, "help", "sub", "cmd")
+	tt.MustAssert(strings.Contains(out, "Usage: tool sub cmd"), out)
+}
+
+func TestHelpCommandInheritedFlags(t *testing.T) {
+	tt := assert.WrapTB(t)
+
+	bld := func() Command {
+		return NewGroup("Root group", Builders{
+			"sub": func() Command {
+				return NewGroup("Sub group", Builders{
+					"cmd": func() Command { return &testCmd{synopsis: "Nested command"} },
+				}, GroupFlags(func() *FlagSet {
+					fs := NewFlagSet()
+					fs.Bool("q", false, "Quiet")
+					fs.MarkInherited("q")
+					return fs
+				}))
+			},
+		}, GroupHelpCommand(), GroupFlags(func() *FlagSet {
+			fs := NewFlagSet()
+			fs.Bool("v", false, "Verbose")
+			fs.MarkInherited("v")
+			return fs
+		}))
+	}
+
+	help := func(args ...string) string {
+		rn := NewBufferedRunner()
+		err := rn.Run(context.Background(), "tool", args, bld)
+		tt.MustAssert(IsHelpRequest(err), err)
+		msg, _ := FormatError(err)
+		return msg
+	}
+
+	out := help("help", "sub", "cmd")
+	tt.MustEqual(help("sub", "cmd", "-help"), out)
+	tt.MustAssert(strings.Contains(out, "Global flags:"), out)
+	tt.MustAssert(strings.Contains(out, "-q    Quiet"), out)
+	tt.MustAssert(strings.Contains(out, "-v    Verbose"), out)
+	tt.MustEqual(help("sub", "-help"), help("help", "sub"))
+}
+
+func TestHelpCommandUsesMatcher(t *testing.T) {
+	tt := assert.WrapTB(t)
+	tt.MustEqual(mustHelp(t, "leaf", "-help"), mustHelp(t, "help", "le"))
+}
+
+func TestHelpCommandTopics(t *testing.T) {
+	tt := assert.WrapTB(t)
+	tt.MustEqual("Supported formats\n\nFormats body", mustHelp(t, "help", "formats"))
+	tt.MustEqual("Environment variables", mustHelp(t, "help", "environment"))
+	tt.MustEqual("Nested topic\n\nNested body", mustHelp(t, "help", "sub", "nested"))
+
+	out := mustHelp(t, "-help")
+	tt.MustAssert(strings.Contains(out, "Help topics:\n    environment  Environment variables\n    formats      Supported formats"), out)
+}
+
+func TestHelpCommandUnknown(t *testing.T) {
+	for _, args := range [][]string{
+		{"help", "nope"},
+		{"help", "leaf", "nope"},
+		{"help", "formats", "nope"},
+	} {
+		t.Run("", func(t *testing.T) {
+			tt := assert.WrapTB(t)
+			rn := NewBufferedRunner()
+			err := rn.Run(context.Background(), "tool", args, helpTestBuilder)
+			tt.MustAssert(IsUsageError(err))
+			tt.MustAssert(!IsHelpRequest(err))
+			tt.MustEqual(ExitUsage, ErrCode(err))
+		})
+	}
+}
+
+func TestHelpCommandDuplicatePanics(t *testing.T) {
+	tt := assert.WrapTB(t)
+	defer func() {
+		tt.MustAssert(recover() != nil)
+	}()
+	NewGroup("Group", Builders{"help": newFooCommand}, GroupHelpCommand())
+}
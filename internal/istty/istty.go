@@ -3,6 +3,7 @@ package istty
 import (
 	"os"
 	"runtime"
+	"strconv"
 )
 
 type TTYState int
@@ -67,3 +68,24 @@ func CheckTTY(v interface{}) TTYState {
 		return IsUnknown
 	}
 }
+
+// TermSize returns the number of columns and rows of the terminal v is
+// connected to. If v is not a terminal, or the size can't be determined
+// on this platform, ok is false.
+//
+// The LINES and COLUMNS environment variables, if set, take precedence.
+func TermSize(v interface{}) (cols, rows int, ok bool) {
+	f, isFile := v.(*os.File)
+	if !isFile || CheckTTY(v) != IsTTY {
+		return 0, 0, false
+	}
+
+	cols, rows, ok = termSize(f)
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		cols = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("LINES")); err == nil && n > 0 {
+		rows = n
+	}
+	return cols, rows, cols > 0 && rows > 0
+}
@@ -0,0 +1,9 @@
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package istty
+
+import "os"
+
+func termSize(f *os.File) (cols, rows int, ok bool) {
+	return 0, 0, false
+}
@@ -0,0 +1,211 @@
+package wrap
+
+import (
+	"strings"
+	"unicode"
+)
+
+// RuneWidth returns the number of terminal columns the rune occupies when
+// displayed: 0 for control characters and combining marks, 2 for East Asian
+// Wide and Fullwidth characters (which includes most emoji), and 1 otherwise.
+//
+// This is an approximation of UAX #11; ambiguous-width characters are treated
+// as narrow, which matches the behaviour of most terminals outside of CJK
+// locales.
+func RuneWidth(r rune) int {
+	switch {
+	case r == 0:
+		return 0
+	case r < 0x20 || (r >= 0x7f && r < 0xa0):
+		return 0
+	case r < 0x300:
+		// Fast path for Latin; nothing below the combining diacriticals block
+		// is zero-width or wide.
+		return 1
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf), unicode.Is(zeroWidth, r):
+		return 0
+	case unicode.Is(wide, r):
+		return 2
+	default:
+		return 1
+	}
+}
+
+// StringWidth returns the number of terminal columns required to display s.
+//
+// ANSI colour escape sequences (ESC '[' ... 'm') do not contribute to the
+// width of the string. If s contains newlines, the width of the widest line
+// is returned.
+func StringWidth(s string) int {
+	var max, cur int
+	var inEsc bool
+	for _, c := range s {
+		if inEsc {
+			if c == 'm' {
+				inEsc = false
+			}
+		} else if c == '\033' {
+			inEsc = true
+		} else if c == '\n' {
+			if cur > max {
+				max = cur
+			}
+			cur = 0
+		} else {
+			cur += RuneWidth(c)
+		}
+	}
+	if cur > max {
+		max = cur
+	}
+	return max
+}
+
+// PadRight appends spaces to s until it occupies at least width terminal
+// columns. It is the display-width-aware equivalent of fmt's "%-*s".
+func PadRight(s string, width int) string {
+	w := StringWidth(s)
+	if w >= width {
+		return s
+	}
+	return s + strings.Repeat(" ", width-w)
+}
+
+// Truncate returns the longest prefix of s that fits within width terminal
+// columns. Multi-byte and wide characters are never split.
+func Truncate(s string, width int) string {
+	var cur int
+	var inEsc bool
+	for i, c := range s {
+		if inEsc {
+			if c == 'm' {
+				inEsc = false
+			}
+			continue
+		} else if c == '\033' {
+			inEsc = true
+			continue
+		}
+		cw := RuneWidth(c)
+		if cur+cw > width {
+			return s[:i]
+		}
+		cur += cw
+	}
+	return s
+}
+
+// isWideBreak reports whether a line may be broken on either side of r. CJK
+// text is not separated by spaces, so a break is allowed between any two wide
+// characters.
+func isWideBreak(r rune) bool {
+	return r >= 0x1100 && RuneWidth(r) == 2
+}
+
+var zeroWidth = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{0x1160, 0x11ff, 1}, // Hangul Jamo medial vowels and final consonants
+		{0x200b, 0x200b, 1}, // Zero width space
+	},
+}
+
+// wide contains the East Asian Wide (W) and Fullwidth (F) ranges, plus the
+// emoji presentation ranges that terminals render using two columns.
+var wide = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{0x1100, 0x115f, 1},
+		{0x231a, 0x231b, 1},
+		{0x2329, 0x232a, 1},
+		{0x23e9, 0x23ec, 1},
+		{0x23f0, 0x23f0, 1},
+		{0x23f3, 0x23f3, 1},
+		{0x25fd, 0x25fe, 1},
+		{0x2614, 0x2615, 1},
+		{0x2648, 0x2653, 1},
+		{0x267f, 0x267f, 1},
+		{0x2693, 0x2693, 1},
+		{0x26a1, 0x26a1, 1},
+		{0x26aa, 0x26ab, 1},
+		{0x26bd, 0x26be, 1},
+		{0x26c4, 0x26c5, 1},
+		{0x26ce, 0x26ce, 1},
+		{0x26d4, 0x26d4, 1},
+		{0x26ea, 0x26ea, 1},
+		{0x26f2, 0x26f3, 1},
+		{0x26f5, 0x26f5, 1},
+		{0x26fa, 0x26fa, 1},
+		{0x26fd, 0x26fd, 1},
+		{0x2705, 0x2705, 1},
+		{0x270a, 0x270b, 1},
+		{0x2728, 0x2728, 1},
+		{0x274c, 0x274c, 1},
+		{0x274e, 0x274e, 1},
+		{0x2753, 0x2755, 1},
+		{0x2757, 0x2757, 1},
+		{0x2795, 0x2797, 1},
+		{0x27b0, 0x27b0, 1},
+		{0x27bf, 0x27bf, 1},
+		{0x2b1b, 0x2b1c, 1},
+		{0x2b50, 0x2b50, 1},
+		{0x2b55, 0x2b55, 1},
+		{0x2e80, 0x303e, 1},
+		{0x3041, 0x33ff, 1},
+		{0x3400, 0x4dbf, 1},
+		{0x4e00, 0x9fff, 1},
+		{0xa000, 0xa4cf, 1},
+		{0xa960, 0xa97f, 1},
+		{0xac00, 0xd7a3, 1},
+		{0xf900, 0xfaff, 1},
+		{0xfe10, 0xfe19, 1},
+		{0xfe30, 0xfe6f, 1},
+		{0xff00, 0xff60, 1},
+		{0xffe0, 0xffe6, 1},
+	},
+	R32: []unicode.Range32{
+		{0x16fe0, 0x16fe4, 1},
+		{0x17000, 0x18cff, 1},
+		{0x1b000, 0x1b2ff, 1},
+		{0x1f004, 0x1f004, 1},
+		{0x1f0cf, 0x1f0cf, 1},
+		{0x1f18e, 0x1f18e, 1},
+		{0x1f191, 0x1f19a, 1},
+		{0x1f200, 0x1f202, 1},
+		{0x1f210, 0x1f23b, 1},
+		{0x1f240, 0x1f248, 1},
+		{0x1f250, 0x1f251, 1},
+		{0x1f260, 0x1f265, 1},
+		{0x1f300, 0x1f320, 1},
+		{0x1f32d, 0x1f335, 1},
+		{0x1f337, 0x1f37c, 1},
+		{0x1f37e, 0x1f393, 1},
+		{0x1f3a0, 0x1f3ca, 1},
+		{0x1f3cf, 0x1f3d3, 1},
+		{0x1f3e0, 0x1f3f0, 1},
+		{0x1f3f4, 0x1f3f4, 1},
+		{0x1f3f8, 0x1f43e, 1},
+		{0x1f440, 0x1f440, 1},
+		{0x1f442, 0x1f4fc, 1},
+		{0x1f4ff, 0x1f53d, 1},
+		{0x1f54b, 0x1f54e, 1},
+		{0x1f550, 0x1f567, 1},
+		{0x1f57a, 0x1f57a, 1},
+		{0x1f595, 0x1f596, 1},
+		{0x1f5a4, 0x1f5a4, 1},
+		{0x1f5fb, 0x1f64f, 1},
+		{0x1f680, 0x1f6c5, 1},
+		{0x1f6cc, 0x1f6cc, 1},
+		{0x1f6d0, 0x1f6d2, 1},
+		{0x1f6d5, 0x1f6d7, 1},
+		{0x1f6dc, 0x1f6df, 1},
+		{0x1f6eb, 0x1f6ec, 1},
+		{0x1f6f4, 0x1f6fc, 1},
+		{0x1f7e0, 0x1f7eb, 1},
+		{0x1f7f0, 0x1f7f0, 1},
+		{0x1f90c, 0x1f93a, 1},
+		{0x1f93c, 0x1f945, 1},
+		{0x1f947, 0x1f9ff, 1},
+		{0x1fa70, 0x1faff, 1},
+		{0x20000, 0x2fffd, 1},
+		{0x30000, 0x3fffd, 1},
+	},
+}
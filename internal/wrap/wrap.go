@@ -44,47 +44,70 @@ func (w Wrapper) Wrap(str string) string {
 				out.WriteString(wrapWith)
 			}
 
-			var (
-				i, j     int
-				c        rune
-				breaking bool
-				inEsc    bool
-			)
-
-			for j, c = range line {
-				if i == width {
-					breaking = true
-					break
-				}
-
-				// FIXME: This tries not to count ASCII escape sequences that change the
-				// colour towards line width, but it breaks badly if the text contains
-				// other escapes or control sequences.
-				if inEsc {
-					if c == 'm' {
-						inEsc = false
-					}
-				} else {
-					if c == '\033' {
-						inEsc = true
-					} else {
-						i++
-					}
-				}
-			}
-
-			cur := line[:j]
-			idx := strings.LastIndexAny(cur, " -")
-			if idx < 0 || !breaking {
+			end, next, breaking := split(line, width)
+			if !breaking {
 				out.WriteString(line)
 				break
-			} else {
-				out.WriteString(line[:idx])
-				line = line[idx+1:]
 			}
+			out.WriteString(line[:end])
+			line = line[next:]
 			ln++
 		}
 	}
 
 	return out.String()
 }
+
+// split finds the point at which line should be broken so that the first
+// part fits within width terminal columns. 'end' is the end of the first part,
+// 'next' is the start of the remainder. If the line fits, or if there is
+// nowhere to break it, breaking is false.
+//
+// Lines may be broken at a space (which is dropped), after a '-', or on either
+// side of a wide (CJK) character.
+func split(line string, width int) (end, next int, breaking bool) {
+	var (
+		cur   int
+		inEsc bool
+		prev  rune
+	)
+
+	for j, c := range line {
+		// FIXME: This tries not to count ASCII escape sequences that change the
+		// colour towards line width, but it breaks badly if the text contains
+		// other escapes or control sequences.
+		if inEsc {
+			if c == 'm' {
+				inEsc = false
+			}
+			continue
+		} else if c == '\033' {
+			inEsc = true
+			continue
+		}
+
+		wideBreak := j > 0 && prev != ' ' && (isWideBreak(c) || isWideBreak(prev))
+
+		cw := RuneWidth(c)
+		if cur+cw > width {
+			if wideBreak {
+				return j, j, true
+			}
+			return end, next, end > 0
+		}
+
+		if c == ' ' {
+			end, next = j, j+1
+		} else if wideBreak {
+			end, next = j, j
+		}
+		if c == '-' && j > 0 && prev != ' ' && prev != '-' {
+			end, next = j+1, j+1
+		}
+
+		cur += cw
+		prev = c
+	}
+
+	return 0, 0, false
+}
@@ -0,0 +1,79 @@
+package wrap
+
+import (
+	"testing"
+
+	"github.com/shabbyrobe/cmdy/internal/assert"
+)
+
+func TestStringWidth(t *testing.T) {
+	for idx, tc := range []struct {
+		in  string
+		out int
+	}{
+		{"", 0},
+		{"abc", 3},
+		{"héllo", 5},
+		{"héllo", 5}, // combining acute accent
+		{"日本語", 6},
+		{"한국어", 6},
+		{"ｆｕｌｌ", 8},
+		{"🎉", 2},
+		{"a‍b", 2}, // zero width joiner
+		{"\033[31mred\033[0m", 3},
+		{"abc\nde", 3},
+		{"ab\n日本語", 6},
+	} {
+		t.Run("", func(t *testing.T) {
+			tt := assert.WrapTB(t)
+			tt.MustEqual(tc.out, StringWidth(tc.in), "%d: %q", idx, tc.in)
+		})
+	}
+}
+
+func TestPadRight(t *testing.T) {
+	tt := assert.WrapTB(t)
+	tt.MustEqual("ab  ", PadRight("ab", 4))
+	tt.MustEqual("日本  ", PadRight("日本", 6))
+	tt.MustEqual("日本語", PadRight("日本語", 4))
+}
+
+func TestTruncate(t *testing.T) {
+	tt := assert.WrapTB(t)
+	tt.MustEqual("abc", Truncate("abcdef", 3))
+	tt.MustEqual("日", Truncate("日本語", 3))
+	tt.MustEqual("日本", Truncate("日本語", 4))
+	tt.MustEqual("héll", Truncate("héllo", 4))
+	tt.MustEqual("abc", Truncate("abc", 10))
+}
+
+func TestWrap(t *testing.T) {
+	for idx, tc := range []struct {
+		width int
+		in    string
+		out   string
+	}{
+		{10, "foo bar baz qux", "foo bar\nbaz qux"},
+		{10, "foobarbazquxfoobar", "foobarbazquxfoobar"},
+		{10, "foo-bar-baz-qux", "foo-bar-\nbaz-qux"},
+		{10, "foo --bar baz", "foo --bar\nbaz"},
+		{7, "日本語日本語", "日本語\n日本語"},
+		{10, "日本語 日本語", "日本語 日\n本語"},
+		{10, "ab 日本語日本語", "ab 日本語\n日本語"},
+		{6, "héllo wörld", "héllo\nwörld"},
+		{8, "🎉🎉🎉🎉🎉", "🎉🎉🎉🎉\n🎉"},
+		{10, "\033[31mfoo\033[0m bar baz", "\033[31mfoo\033[0m bar\nbaz"},
+	} {
+		t.Run("", func(t *testing.T) {
+			tt := assert.WrapTB(t)
+			result := Wrapper{Width: tc.width}.Wrap(tc.in)
+			tt.MustEqual(tc.out, result, "%d: %q", idx, tc.in)
+		})
+	}
+}
+
+func TestWrapIndent(t *testing.T) {
+	tt := assert.WrapTB(t)
+	result := Wrapper{Width: 7, Indent: "  ", IndentFirst: true}.Wrap("日本語日本語")
+	tt.MustEqual("  日本語\n  日本語", result)
+}
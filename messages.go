@@ -0,0 +1,143 @@
+package cmdy
+
+import (
+	"fmt"
+
+	"github.com/shabbyrobe/cmdy/arg"
+)
+
+// Messages contains the user-facing strings cmdy uses when it builds help
+// messages and reports errors. To translate them, assign a modified copy of
+// DefaultMessages() to Runner.Messages:
+//
+//	msgs := cmdy.DefaultMessages()
+//	msgs.Usage = "Utilisation : "
+//	msgs.UnknownCommand = "commande inconnue %[1]q"
+//	runner.Messages = msgs
+//
+// Strings that contain formatting verbs are passed to fmt.Sprintf. The
+// arguments are documented next to each field. Always use explicit argument
+// indexes (i.e. '%[2]d'); this allows translations to change the order of the
+// arguments, or to leave some out, without fmt complaining.
+//
+// Errors returned by the flag package in the stdlib can not be translated.
+type Messages struct {
+	// Help message section headings:
+	Usage       string
+	Flags       string
+	GlobalFlags string
+	Arguments   string
+	Examples    string
+	Commands    string
+	HelpTopics  string
+	ExitCodes   string
+
+	// Synopsis for the help command added by GroupHelpCommand, shown in the
+	// Group's list of commands.
+	HelpCommandSynopsis string
+
+	// Shown after the help message when a usage error wraps another error.
+	// Args: message
+	Error string
+
+	// Shown after the message of a DetailedError with a Hint or Doc.
+	// Args: hint or doc
+	ErrorHint string
+	ErrorDoc  string
+
+	// Returned by the Error() method of usage errors that don't wrap another
+	// error.
+	HelpRequested string
+	UsageError    string
+
+	// Args: command name
+	UnknownCommand string
+
+	// Args: name
+	UnknownHelpTopic string
+
+	// Args: command path
+	NoSubcommands string
+
+	// Args: arg description, position
+	ArgMissing string
+
+	// Args: arg description, position, error, input
+	ArgInvalid string
+
+	// Args: count, position
+	ArgExtra       string
+	ArgExtraPlural string
+
+	// Args: bound, position, found
+	ArgRemainingTooFew  string
+	ArgRemainingTooMany string
+}
+
+// DefaultMessages returns a new copy of the English Messages used by cmdy
+// when Runner.Messages is nil.
+func DefaultMessages() *Messages {
+	return &Messages{
+		Usage:       "Usage: ",
+		Flags:       "Flags:",
+		GlobalFlags: "Global flags:",
+		Arguments:   "Arguments:",
+		Examples:    "Examples:",
+		Commands:    "Commands:",
+		HelpTopics:  "Help topics:",
+		ExitCodes:   "Exit codes:",
+
+		HelpCommandSynopsis: "Show help for a command or topic",
+
+		Error:         "error: %[1]s",
+		HelpRequested: "help requested",
+		UsageError:    "usage error",
+
+		ErrorHint: "hint: %[1]s",
+		ErrorDoc:  "see: %[1]s",
+
+		UnknownCommand:   "unknown command %[1]q",
+		UnknownHelpTopic: "unknown command or help topic %[1]q",
+		NoSubcommands:    "command %[1]q has no subcommands",
+
+		ArgMissing:          "missing arg %[1]s at position %[2]d",
+		ArgInvalid:          "arg invalid at position %[2]d: %[3]v",
+		ArgExtra:            "found %[1]d additional arg",
+		ArgExtraPlural:      "found %[1]d additional args",
+		ArgRemainingTooFew:  "expected at least %[1]d remaining args at position %[2]d, found %[3]d",
+		ArgRemainingTooMany: "expected at most %[1]d remaining args at position %[2]d, found %[3]d",
+	}
+}
+
+// defaultMessages is used when no Runner is available. It must not be modified.
+var defaultMessages = DefaultMessages()
+
+func (r *Runner) messages() *Messages {
+	if r == nil || r.Messages == nil {
+		return defaultMessages
+	}
+	return r.Messages
+}
+
+// errorText renders errors that cmdy knows how to translate; all others are
+// returned using their Error() method.
+func (m *Messages) errorText(err error) string {
+	switch err := err.(type) {
+	case *arg.MissingArgError:
+		return fmt.Sprintf(m.ArgMissing, err.Arg.Describe("", ""), err.Position)
+	case *arg.InvalidArgError:
+		return fmt.Sprintf(m.ArgInvalid, err.Arg.Describe("", ""), err.Position, err.Err, err.Input)
+	case *arg.ExtraArgsError:
+		if err.Count == 1 {
+			return fmt.Sprintf(m.ArgExtra, err.Count, err.Position)
+		}
+		return fmt.Sprintf(m.ArgExtraPlural, err.Count, err.Position)
+	case *arg.RemainingCountError:
+		if err.TooFew() {
+			return fmt.Sprintf(m.ArgRemainingTooFew, err.Range.Min, err.Position, err.Found)
+		}
+		return fmt.Sprintf(m.ArgRemainingTooMany, err.Range.Max, err.Position, err.Found)
+	default:
+		return err.Error()
+	}
+}
@@ -0,0 +1,88 @@
+package cmdy
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/shabbyrobe/cmdy/arg"
+	"github.com/shabbyrobe/cmdy/internal/assert"
+)
+
+func testMessages() *Messages {
+	msgs := DefaultMessages()
+	msgs.Usage = "Utilisation : "
+	msgs.Flags = "Options :"
+	msgs.Arguments = "Arguments :"
+	msgs.Commands = "Commandes :"
+	msgs.Error = "erreur : %[1]s"
+	msgs.HelpRequested = "aide demandée"
+	msgs.UnknownCommand = "commande inconnue %[1]q"
+	msgs.ArgMissing = "argument %[1]s manquant en position %[2]d"
+	msgs.ArgExtra = "%[1]d argument supplémentaire"
+	msgs.ArgInvalid = "argument %[1]s invalide en position %[2]d : %[3]v"
+	return msgs
+}
+
+func TestMessagesTranslateArgErrors(t *testing.T) {
+	tt := assert.WrapTB(t)
+
+	var foo int
+	as := arg.NewArgSet()
+	as.Int(&foo, "foo", "usage!")
+	fs := NewFlagSet()
+	fs.Bool("yep", false, "usage!")
+	bld := testBuilder(&testCmd{args: as, flags: fs})
+
+	rn := NewBufferedRunner()
+	rn.Messages = testMessages()
+
+	err := rn.Run(context.Background(), "test", nil, bld)
+	msg, code := FormatError(err)
+	tt.MustEqual(ExitUsage, code)
+	tt.MustAssert(strings.Contains(msg, "Utilisation : test"), msg)
+	tt.MustAssert(strings.Contains(msg, "Options :\n"), msg)
+	tt.MustAssert(strings.Contains(msg, "Arguments :\n"), msg)
+	tt.MustAssert(strings.HasSuffix(msg, "erreur : argument <foo> manquant en position 1"), msg)
+
+	err = rn.Run(context.Background(), "test", []string{"1", "2"}, bld)
+	msg, _ = FormatError(err)
+	tt.MustAssert(strings.HasSuffix(msg, "erreur : 1 argument supplémentaire"), msg)
+
+	err = rn.Run(context.Background(), "test", []string{"x"}, bld)
+	msg, _ = FormatError(err)
+	tt.MustAssert(strings.Contains(msg, "erreur : argument <foo> invalide en position 1 : "), msg)
+
+	// The untranslated error is still available:
+	var invalid *arg.InvalidArgError
+	tt.MustAssert(errors.As(err, &invalid))
+	tt.MustEqual(1, invalid.Position)
+}
+
+func TestMessagesTranslateGroup(t *testing.T) {
+	tt := assert.WrapTB(t)
+
+	bld := func() Command {
+		return NewGroup("group", Builders{"foo": newFooCommand})
+	}
+
+	rn := NewBufferedRunner()
+	rn.Messages = testMessages()
+
+	err := rn.Run(context.Background(), "test", []string{"nope"}, bld)
+	msg, _ := FormatError(err)
+	tt.MustAssert(strings.Contains(msg, "Commandes :\n    foo"), msg)
+	tt.MustAssert(strings.HasSuffix(msg, `erreur : commande inconnue "nope"`), msg)
+
+	err = rn.Run(context.Background(), "test", []string{"-help"}, bld)
+	tt.MustEqual("aide demandée", err.Error())
+}
+
+func TestDefaultMessagesUnchanged(t *testing.T) {
+	tt := assert.WrapTB(t)
+	msgs := DefaultMessages()
+	msgs.Usage = "yep"
+	tt.MustEqual("Usage: ", DefaultMessages().Usage)
+	tt.MustEqual("Usage: ", defaultMessages.Usage)
+}
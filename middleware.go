@@ -0,0 +1,39 @@
+package cmdy
+
+// RunFunc runs a Command. Command.Run is a RunFunc.
+type RunFunc func(ctx Context) error
+
+// Middleware wraps the RunFunc of every Command run by a Runner, including
+// Groups and the subcommands they dispatch to, so a command nested two Groups
+// deep is wrapped three times. Middleware is called after the Command's flags
+// and args have been parsed; the parsed FlagSet and ArgSet are available from
+// ctx.Current().
+//
+// Middleware calls next to run the Command, and usually returns the error it
+// returns, though it may replace it. Returning without calling next stops the
+// Command from running:
+//
+//	func Timer(next cmdy.RunFunc) cmdy.RunFunc {
+//		return func(ctx cmdy.Context) error {
+//			start := time.Now()
+//			err := next(ctx)
+//			log.Printf("%s took %s", ctx.Stack().Invocation(), time.Since(start))
+//			return err
+//		}
+//	}
+//
+// Middleware is not called if parsing fails, or if the '-help' flag is passed.
+type Middleware func(next RunFunc) RunFunc
+
+// Use appends Middleware to the Runner. Middleware is called in the order it
+// is added, so the first Middleware added is the outermost.
+func (r *Runner) Use(mw ...Middleware) {
+	r.Middleware = append(r.Middleware, mw...)
+}
+
+func (r *Runner) wrapRun(run RunFunc) RunFunc {
+	for i := len(r.Middleware) - 1; i >= 0; i-- {
+		run = r.Middleware[i](run)
+	}
+	return run
+}
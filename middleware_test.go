@@ -0,0 +1,124 @@
+package cmdy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/shabbyrobe/cmdy/arg"
+	"github.com/shabbyrobe/cmdy/internal/assert"
+)
+
+func TestMiddlewareOrder(t *testing.T) {
+	tt := assert.WrapTB(t)
+
+	var calls []string
+	record := func(name string) Middleware {
+		return func(next RunFunc) RunFunc {
+			return func(ctx Context) error {
+				calls = append(calls, name+">"+ctx.Stack().Invocation())
+				err := next(ctx)
+				calls = append(calls, name+"<"+ctx.Stack().Invocation())
+				return err
+			}
+		}
+	}
+
+	bld := func() Command {
+		return NewGroup("grp", Builders{
+			"sub": testCmdRunBuilder(func(c Context) error {
+				calls = append(calls, "run")
+				return nil
+			}),
+		})
+	}
+
+	rn := NewBufferedRunner()
+	rn.Use(record("a"), record("b"))
+	tt.MustOK(rn.Run(context.Background(), "test", []string{"sub"}, bld))
+
+	tt.MustEqual([]string{
+		"a>test", "b>test",
+		"a>test sub", "b>test sub",
+		"run",
+		"b<test sub", "a<test sub",
+		"b<test", "a<test",
+	}, calls)
+}
+
+func TestMiddlewareSeesParsedFlagsAndArgs(t *testing.T) {
+	tt := assert.WrapTB(t)
+
+	var foo, bar string
+	cmd := &testCmd{configure: func(flags *FlagSet, args *arg.ArgSet) {
+		flags.StringVar(&foo, "foo", "", "")
+		args.String(&bar, "bar", "")
+	}}
+
+	var seen []string
+	rn := NewBufferedRunner()
+	rn.Use(func(next RunFunc) RunFunc {
+		return func(ctx Context) error {
+			cur := ctx.Current()
+			seen = append(seen, cur.Flags.Lookup("foo").Value.String(), cur.Args.Usage())
+			return next(ctx)
+		}
+	})
+
+	tt.MustOK(rn.Run(context.Background(), "test", []string{"-foo", "yep", "baz"}, cmd.AsBuilder()))
+	tt.MustEqual("yep", seen[0])
+	tt.MustAssert(strings.Contains(seen[1], "<bar>"))
+}
+
+func TestMiddlewareCanReplaceError(t *testing.T) {
+	tt := assert.WrapTB(t)
+
+	denied := errors.New("denied")
+	rn := NewBufferedRunner()
+	rn.Use(func(next RunFunc) RunFunc {
+		return func(ctx Context) error {
+			if ctx.Current().Name == "secret" {
+				return UsageError(denied)
+			}
+			return next(ctx)
+		}
+	})
+
+	var ran bool
+	bld := func() Command {
+		return NewGroup("grp", Builders{
+			"secret": testCmdRunBuilder(func(c Context) error { ran = true; return nil }),
+		})
+	}
+
+	err := rn.Run(context.Background(), "test", []string{"secret"}, bld)
+	tt.MustAssert(!ran)
+	tt.MustAssert(errors.Is(err, denied))
+
+	// Usage errors returned by middleware still get the command's usage:
+	msg, code := FormatError(err)
+	tt.MustEqual(ExitUsage, code)
+	tt.MustAssert(strings.Contains(msg, "Usage: test secret"), msg)
+}
+
+func TestMiddlewareNotCalledOnParseFailure(t *testing.T) {
+	tt := assert.WrapTB(t)
+
+	var calls int
+	rn := NewBufferedRunner()
+	rn.Use(func(next RunFunc) RunFunc {
+		return func(ctx Context) error {
+			calls++
+			return next(ctx)
+		}
+	})
+
+	cmd := &testCmd{}
+	for _, args := range [][]string{{"-nope"}, {"-help"}, {"extra"}} {
+		err := rn.Run(context.Background(), "test", args, cmd.AsBuilder())
+		tt.MustAssert(IsUsageError(err), fmt.Sprint(args))
+	}
+	tt.MustEqual(0, calls)
+}
@@ -0,0 +1,108 @@
+package cmdy
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/shabbyrobe/cmdy/cmdstr"
+	"github.com/shabbyrobe/cmdy/internal/istty"
+)
+
+// PagerEnv is the name of an environment variable that can be used to override
+// Runner.Pager at runtime. Valid values are "never", "auto" and "always".
+const PagerEnv = "CMDY_PAGER"
+
+// DefaultPager is the command used to page help output if the PAGER
+// environment variable is not set.
+const DefaultPager = "less -R"
+
+// PagerMode controls whether Runner.Fatal sends help output through a pager
+// like 'less'. The pager is only ever used if Runner.Stderr is a terminal.
+//
+// The pager command is taken from the PAGER environment variable, falling back
+// to DefaultPager if it is not set. If PAGER is set to an empty string or to
+// 'cat', the pager is disabled.
+type PagerMode int
+
+const (
+	// PagerNever writes help output directly to Runner.Stderr.
+	PagerNever PagerMode = iota
+
+	// PagerAuto pages help output if it is taller than the terminal.
+	PagerAuto
+
+	// PagerAlways pages help output regardless of its length.
+	PagerAlways
+)
+
+func parsePagerMode(s string) (mode PagerMode, ok bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "never":
+		return PagerNever, true
+	case "auto":
+		return PagerAuto, true
+	case "always":
+		return PagerAlways, true
+	default:
+		return PagerNever, false
+	}
+}
+
+func pagerCommand(lookupEnv func(string) (string, bool)) []string {
+	pager, ok := lookupEnv("PAGER")
+	if !ok {
+		pager = DefaultPager
+	}
+	args, err := cmdstr.ParseString(pager, "")
+	if err != nil || len(args) == 0 || args[0] == "cat" {
+		return nil
+	}
+	return args
+}
+
+// page attempts to send msg to the pager. If it returns false, the message
+// has not been written anywhere and the caller should fall back to writing
+// it directly.
+func (r *Runner) page(msg string) (paged bool) {
+	mode := r.Pager
+	if env, ok := os.LookupEnv(PagerEnv); ok {
+		if m, ok := parsePagerMode(env); ok {
+			mode = m
+		}
+	}
+	if mode == PagerNever {
+		return false
+	}
+
+	out, ok := r.Stderr.(*os.File)
+	if !ok {
+		return false
+	}
+	_, rows, ok := istty.TermSize(out)
+	if !ok {
+		return false
+	}
+	if mode == PagerAuto && strings.Count(msg, "\n")+1 < rows {
+		return false
+	}
+
+	args := pagerCommand(os.LookupEnv)
+	if args == nil {
+		return false
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = strings.NewReader(msg + "\n")
+	cmd.Stdout = out
+	cmd.Stderr = out
+
+	// If the pager can't be started, nothing has been written yet so it is
+	// safe to fall back. Once it has started, we can't tell how much of the
+	// message the user has seen, so any subsequent error is ignored.
+	if err := cmd.Start(); err != nil {
+		return false
+	}
+	_ = cmd.Wait()
+	return true
+}
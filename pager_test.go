@@ -0,0 +1,47 @@
+package cmdy
+
+import (
+	"testing"
+
+	"github.com/shabbyrobe/cmdy/internal/assert"
+)
+
+func TestParsePagerMode(t *testing.T) {
+	tt := assert.WrapTB(t)
+	for in, exp := range map[string]PagerMode{
+		"never":    PagerNever,
+		"auto":     PagerAuto,
+		"ALWAYS":   PagerAlways,
+		" always ": PagerAlways,
+	} {
+		mode, ok := parsePagerMode(in)
+		tt.MustAssert(ok, in)
+		tt.MustEqual(exp, mode, in)
+	}
+
+	_, ok := parsePagerMode("yep")
+	tt.MustAssert(!ok)
+}
+
+func TestPagerCommand(t *testing.T) {
+	env := func(vars map[string]string) func(string) (string, bool) {
+		return func(k string) (string, bool) {
+			v, ok := vars[k]
+			return v, ok
+		}
+	}
+
+	tt := assert.WrapTB(t)
+	tt.MustEqual([]string{"less", "-R"}, pagerCommand(env(nil)))
+	tt.MustEqual([]string{"more"}, pagerCommand(env(map[string]string{"PAGER": "more"})))
+	tt.MustEqual([]string{"less", "-F", "-X"}, pagerCommand(env(map[string]string{"PAGER": "less -F -X"})))
+	tt.MustEqual([]string(nil), pagerCommand(env(map[string]string{"PAGER": ""})))
+	tt.MustEqual([]string(nil), pagerCommand(env(map[string]string{"PAGER": "cat"})))
+}
+
+func TestPagerNotUsedWithoutTerminal(t *testing.T) {
+	tt := assert.WrapTB(t)
+	rn := NewBufferedRunner()
+	rn.Pager = PagerAlways
+	tt.MustAssert(!rn.page("yep"))
+}
@@ -0,0 +1,128 @@
+package cmdy
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"runtime/debug"
+	"strings"
+)
+
+// DebugEnv is the name of an environment variable that, if set to a non-empty
+// value, causes panics recovered by a Runner to be reported to the Runner's
+// Stderr instead of to a file. See Runner.RecoverPanics.
+const DebugEnv = "CMDY_DEBUG"
+
+// PanicError is returned by Runner.Run in place of a panic if
+// Runner.RecoverPanics is set.
+//
+// When it is returned from the outermost call to Runner.Run, a crash report
+// containing the panic value, the command path and the stack trace of the
+// panic has already been written. The report is written to a temporary file,
+// or to the Runner's Stderr if the DebugEnv environment variable is set.
+type PanicError struct {
+	// Value passed to panic().
+	Value interface{}
+
+	// Path to the command that panicked. Only the Name of the last entry is
+	// set.
+	Path CommandPath
+
+	// Stack trace of the goroutine that panicked, as returned by debug.Stack().
+	Stack []byte
+
+	// Details are extra lines included in the crash report, like the version
+	// of the program. Runner.OnPanic can be used to add them.
+	Details []string
+
+	// ReportFile is the name of the file the crash report was written to. It
+	// is empty if the report has not been written, or if it was written to the
+	// Runner's Stderr.
+	ReportFile string
+
+	reported bool
+}
+
+func (p *PanicError) Code() int { return ExitInternal }
+
+func (p *PanicError) Error() string {
+	msg := fmt.Sprintf("internal error in %q: %v", p.Path.Invocation(), p.Value)
+	if p.ReportFile != "" {
+		msg += "\ncrash report written to " + p.ReportFile
+	}
+	return msg
+}
+
+// Unwrap returns the panic value if it is an error.
+func (p *PanicError) Unwrap() error {
+	err, _ := p.Value.(error)
+	return err
+}
+
+// Report returns the text of the crash report.
+func (p *PanicError) Report() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "panic: %v\n", p.Value)
+	fmt.Fprintf(&sb, "command: %s\n", p.Path.Invocation())
+	for _, line := range p.Details {
+		sb.WriteString(line)
+		sb.WriteByte('\n')
+	}
+	sb.WriteByte('\n')
+	sb.Write(p.Stack)
+	return sb.String()
+}
+
+// recoverPanic is deferred by Runner.Run. It must be called directly by defer
+// for recover() to work.
+func (r *Runner) recoverPanic(ctx context.Context, name string, rerr *error) {
+	if v := recover(); v != nil {
+		var path CommandPath
+		if cctx, ok := ctx.(*commandContext); ok {
+			path = append(path, cctx.Stack()...)
+		}
+		path = append(path, CommandRef{Name: name})
+		*rerr = &PanicError{Value: v, Path: path, Stack: debug.Stack()}
+	}
+
+	// The crash report is only written once the error reaches the outermost
+	// Run, so the OnPanic hook is called once no matter how deeply nested the
+	// command that panicked was:
+	if _, nested := ctx.(*commandContext); nested {
+		return
+	}
+	if perr, ok := findError(*rerr, isPanicError).(*PanicError); ok && !perr.reported {
+		r.reportPanic(perr)
+	}
+}
+
+func isPanicError(err error) bool { _, ok := err.(*PanicError); return ok }
+
+func (r *Runner) reportPanic(perr *PanicError) {
+	perr.reported = true
+	if r.OnPanic != nil {
+		r.OnPanic(perr)
+	}
+
+	report := perr.Report()
+	if os.Getenv(DebugEnv) == "" {
+		if name, err := writeCrashReport(report); err == nil {
+			perr.ReportFile = name
+			return
+		}
+	}
+	fmt.Fprintln(r.Stderr, report)
+}
+
+func writeCrashReport(report string) (name string, err error) {
+	f, err := ioutil.TempFile("", "cmdy-crash-*.txt")
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.WriteString(report); err != nil {
+		f.Close()
+		return "", err
+	}
+	return f.Name(), f.Close()
+}
@@ -0,0 +1,97 @@
+package cmdy
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/shabbyrobe/cmdy/internal/assert"
+)
+
+func panicGroupBuilder(v interface{}) Builder {
+	return func() Command {
+		return NewGroup("grp", Builders{
+			"sub": testCmdRunBuilder(func(c Context) error {
+				panic(v)
+			}),
+		})
+	}
+}
+
+func TestRecoverPanicsDisabled(t *testing.T) {
+	tt := assert.WrapTB(t)
+
+	defer func() {
+		tt.MustEqual("boom", recover())
+	}()
+	rn := NewBufferedRunner()
+	rn.Run(context.Background(), "test", []string{"sub"}, panicGroupBuilder("boom"))
+	t.Fatal("expected panic")
+}
+
+func TestRecoverPanicsReportFile(t *testing.T) {
+	tt := assert.WrapTB(t)
+	os.Unsetenv(DebugEnv)
+
+	var hooked int
+	rn := NewBufferedRunner()
+	rn.RecoverPanics = true
+	rn.OnPanic = func(perr *PanicError) {
+		hooked++
+		perr.Details = append(perr.Details, "version: 1.2.3")
+	}
+
+	err := rn.Run(context.Background(), "test", []string{"sub"}, panicGroupBuilder("boom"))
+	var perr *PanicError
+	tt.MustAssert(errors.As(err, &perr))
+	tt.MustEqual(1, hooked)
+	tt.MustEqual("test sub", perr.Path.Invocation())
+	tt.MustEqual("", rn.StderrBuffer.String())
+
+	tt.MustAssert(perr.ReportFile != "")
+	defer os.Remove(perr.ReportFile)
+
+	report, rerr := ioutil.ReadFile(perr.ReportFile)
+	tt.MustOK(rerr)
+	tt.MustAssert(strings.HasPrefix(string(report), "panic: boom\ncommand: test sub\nversion: 1.2.3\n\n"), string(report))
+	tt.MustAssert(strings.Contains(string(report), "panicGroupBuilder"), string(report))
+
+	msg, code := FormatError(err)
+	tt.MustEqual(ExitInternal, code)
+	tt.MustEqual("internal error in \"test sub\": boom\ncrash report written to "+perr.ReportFile, msg)
+}
+
+func TestRecoverPanicsDebugEnv(t *testing.T) {
+	tt := assert.WrapTB(t)
+	os.Setenv(DebugEnv, "1")
+	defer os.Unsetenv(DebugEnv)
+
+	rn := NewBufferedRunner()
+	rn.RecoverPanics = true
+
+	cause := errors.New("boom")
+	err := rn.Run(context.Background(), "test", []string{"sub"}, panicGroupBuilder(cause))
+	tt.MustAssert(errors.Is(err, cause))
+
+	var perr *PanicError
+	tt.MustAssert(errors.As(err, &perr))
+	tt.MustEqual("", perr.ReportFile)
+	tt.MustAssert(strings.HasPrefix(rn.StderrBuffer.String(), "panic: boom\ncommand: test sub\n"))
+	tt.MustEqual("internal error in \"test sub\": boom", err.Error())
+}
+
+func TestRecoverPanicsInBuilder(t *testing.T) {
+	tt := assert.WrapTB(t)
+	os.Setenv(DebugEnv, "1")
+	defer os.Unsetenv(DebugEnv)
+
+	rn := NewBufferedRunner()
+	rn.RecoverPanics = true
+
+	err := rn.Run(context.Background(), "test", nil, func() Command { panic("nope") })
+	tt.MustEqual(ExitInternal, ErrCode(err))
+	tt.MustEqual("internal error in \"test\": nope", err.Error())
+}
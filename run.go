@@ -38,15 +38,49 @@ type Runner struct {
 	Stdin  io.Reader
 	Stdout io.Writer
 	Stderr io.Writer
+
+	// Messages contains the user-facing strings used in help messages and
+	// errors. If nil, DefaultMessages() is used.
+	Messages *Messages
+
+	// Pager controls whether long help output printed by Fatal is sent through
+	// a pager. It can be overridden at runtime using the CMDY_PAGER environment
+	// variable. See PagerMode.
+	Pager PagerMode
+
+	// Middleware wraps the Run method of every Command run by the Runner.
+	// See Middleware and Runner.Use.
+	Middleware []Middleware
+
+	// If RecoverPanics is set, panics raised while building, configuring or
+	// running a Command are recovered and returned from Run as a *PanicError,
+	// which Fatal reports with the code ExitInternal. A crash report containing
+	// the stack trace is written to a temporary file, or to Stderr if the
+	// DebugEnv environment variable is set.
+	RecoverPanics bool
+
+	// OnPanic, if set, is called with each panic recovered by the Runner
+	// before the crash report is written. It can be used to add Details to the
+	// report, or to send it somewhere else. See RecoverPanics.
+	OnPanic func(perr *PanicError)
+
+	// Services provides shared values to Commands via Resolve. See Services.
+	Services *Services
+
+	// ErrorFormat controls how Fatal prints errors. It can be overridden at
+	// runtime using the CMDY_ERROR_FORMAT environment variable. See
+	// ErrorFormat.
+	ErrorFormat ErrorFormat
 }
 
 // NewStandardRunner returns a Runner configured to use os.Stdin, os.Stdout and
-// os.Stderr.
+// os.Stderr. Help output that does not fit in the terminal is paged.
 func NewStandardRunner() *Runner {
 	return &Runner{
 		Stdin:  os.Stdin,
 		Stdout: os.Stdout,
 		Stderr: os.Stderr,
+		Pager:  PagerAuto,
 	}
 }
 
@@ -59,40 +93,74 @@ func NewStandardRunner() *Runner {
 // the program's name from os.Args[0].
 //
 func (r *Runner) Run(ctx context.Context, name string, args []string, b Builder) (rerr error) {
-	var (
-		cmd     = b()
-		flagSet *FlagSet
-		argSet  *arg.ArgSet
-	)
-
-	// FIXME: see if we can remove this; only a test depends on it at the moment:
-	if acmd, ok := cmd.(interface{ Args() *arg.ArgSet }); ok {
-		argSet = acmd.Args()
-	}
-	if fcmd, ok := cmd.(interface{ Flags() *FlagSet }); ok {
-		flagSet = fcmd.Flags()
+	if r.RecoverPanics {
+		defer r.recoverPanic(ctx, name, &rerr)
 	}
 
-	if argSet == nil {
-		argSet = arg.NewArgSet()
-	}
-	if flagSet == nil {
-		flagSet = NewFlagSet()
-	}
-	cmd.Configure(flagSet, argSet)
+	cmd := b()
+	flagSet, argSet := configure(cmd)
 
 	cctx, ok := ctx.(*commandContext)
 	if !ok {
+		defer func() {
+			if rerr == nil {
+				return
+			}
+			path := cctx.failedPath
+			if path == nil {
+				path = []string{name}
+			}
+			recordFailedRun(rerr, path)
+		}()
+
+		services := &serviceScope{services: r.Services}
+		defer services.close(&rerr)
+
+		// Cleanups run before services are closed, as they may use them. If
+		// the Cleanups were supplied by the caller using WithCleanups (for
+		// example, by cmdyutil.InterruptRunner), they are still run here;
+		// Cleanups.Run only runs each function once, so the caller may safely
+		// run them again if it stops waiting for us. The Cleanups of a command
+		// that starts a nested Run are never adopted, otherwise the nested
+		// Run would run them early:
+		cleanups := suppliedCleanups(ctx)
+		if cleanups == nil {
+			cleanups = &Cleanups{}
+		}
+		ctx = context.WithValue(ctx, cleanupsKey{}, cleanupsValue{cleanups: cleanups})
+		defer func() {
+			if err := cleanups.Run(); err != nil && rerr == nil {
+				rerr = err
+			}
+		}()
+
 		cctx = &commandContext{
-			Context: ctx,
-			cmd:     cmd,
-			rawArgs: args,
-			runner:  r,
+			Context:  ctx,
+			cmd:      cmd,
+			rawArgs:  args,
+			runner:   r,
+			services: services,
 		}
 	}
 
+	flagSet.inheritPath(cctx.parents)
+
 	cctx.Push(name, cmd)
 	defer cctx.Pop()
+	defer func() {
+		// The innermost command to fail is recorded first:
+		if rerr != nil && cctx.failedPath == nil {
+			cctx.failedPath = cctx.Stack().Names()
+		}
+	}()
+	defer func() {
+		if sc := cctx.Current().scope; sc != nil {
+			sc.close(&rerr)
+		}
+	}()
+
+	top := &cctx.parents[len(cctx.parents)-1]
+	top.Flags, top.Args = flagSet, argSet
 
 	defer func() {
 		// when a nested command raises a usage error, we only want the topmost
@@ -100,13 +168,15 @@ func (r *Runner) Run(ctx context.Context, name string, args []string, b Builder)
 		// gets called for all commands on the stack. checking uerr.usage
 		// prevents all parents of the command that raised the usageError from
 		// clobbering the already-built usage.
-		if uerr, ok := rerr.(*usageError); ok && uerr.usage == "" {
+		if uerr := findUsageError(rerr); uerr != nil && uerr.usage == "" {
+			msgs := r.messages()
 			path := cctx.Stack()
-			help, err := buildHelp(cmd, path, flagSet, argSet)
+			help, err := buildHelp(msgs, cmd, path, flagSet, argSet)
 			if err != nil {
 				panic(err)
 			}
 			uerr.usage = help
+			uerr.msgs = msgs
 		}
 	}()
 
@@ -128,19 +198,56 @@ func (r *Runner) Run(ctx context.Context, name string, args []string, b Builder)
 		return UsageError(err)
 	}
 
-	return cmd.Run(cctx)
+	return r.wrapRun(cmd.Run)(cctx)
+}
+
+func configure(cmd Command) (flagSet *FlagSet, argSet *arg.ArgSet) {
+	// FIXME: see if we can remove this; only a test depends on it at the moment:
+	if acmd, ok := cmd.(interface{ Args() *arg.ArgSet }); ok {
+		argSet = acmd.Args()
+	}
+	if fcmd, ok := cmd.(interface{ Flags() *FlagSet }); ok {
+		flagSet = fcmd.Flags()
+	}
+
+	if argSet == nil {
+		argSet = arg.NewArgSet()
+	}
+	if flagSet == nil {
+		flagSet = NewFlagSet()
+	}
+	cmd.Configure(flagSet, argSet)
+	return flagSet, argSet
 }
 
 // Fatal prints an error formatted for the end user, then calls os.Exit with
 // the exit code detected in err.
 //
+// If err is a usage error or a help request, the help message may be sent
+// through a pager, depending on the value of Runner.Pager.
+//
+// If Runner.ErrorFormat is ErrorFormatJSON, an ErrorReport is printed as JSON
+// instead.
+//
 // Calls to Fatal() will prevent any defer calls from running. See cmdy.Fatal()
 // for a demonstration of the recommended usage pattern for dealing with Fatal
 // errors.
 //
 func (r *Runner) Fatal(err error) {
-	msg, code := FormatError(err)
-	if msg != "" {
+	os.Exit(r.printError(err))
+}
+
+func (r *Runner) printError(err error) (code int) {
+	if err != nil && r.errorFormat() == ErrorFormatJSON {
+		if jerr := r.writeJSONError(err); jerr != nil {
+			panic(jerr)
+		}
+		_, code = r.FormatError(err)
+		return code
+	}
+
+	msg, code := r.FormatError(err)
+	if msg != "" && !(IsUsageError(err) && r.page(msg)) {
 		if _, err := io.WriteString(r.Stderr, msg); err != nil {
 			panic(err)
 		}
@@ -148,7 +255,7 @@ func (r *Runner) Fatal(err error) {
 			panic(err)
 		}
 	}
-	os.Exit(code)
+	return code
 }
 
 // Run the command built by Builder b using the DefaultRunner, passing in the
@@ -0,0 +1,271 @@
+package cmdy
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Services is a registry of shared values, like database handles, loggers and
+// API clients, that can be resolved by type from a Context using Resolve.
+//
+// Builders should be cheap to call and free of dependencies, so instead of
+// opening resources when a Command is built, register a constructor with the
+// Runner's Services once, and Resolve it from the Command's Run method:
+//
+//	services := &cmdy.Services{}
+//	services.Provide(func(ctx cmdy.Context) (*sql.DB, error) {
+//		return sql.Open("postgres", os.Getenv("DSN"))
+//	})
+//	runner.Services = services
+//
+//	func (cmd *myCommand) Run(ctx cmdy.Context) error {
+//		var db *sql.DB
+//		if err := cmdy.Resolve(ctx, &db); err != nil {
+//			return err
+//		}
+//		...
+//	}
+//
+// Constructors are called lazily, the first time their type is resolved, and
+// at most once per call to Runner.Run. Values returned by constructors that
+// implement io.Closer are closed in reverse order of construction when the
+// Runner.Run call they were resolved in returns. Values registered with
+// ProvideValue are never closed. Constructors can Resolve other services using
+// the Context they are passed; if that leads back to the type being
+// constructed, Resolve returns an error wrapping ErrServiceCycle.
+//
+// A Group's Before hook can register values that are only visible to its
+// subcommands using Scope.
+//
+// The zero value is ready to use. Services must not be modified while a Runner
+// that uses it is running.
+type Services struct {
+	providers map[reflect.Type]*serviceProvider
+}
+
+type serviceProvider struct {
+	construct func(ctx Context) (reflect.Value, error)
+	close     bool
+}
+
+var contextType = reflect.TypeOf((*Context)(nil)).Elem()
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// Provide registers a constructor for the type it returns. The constructor
+// must be a function with the signature 'func(cmdy.Context) (T, error)' or
+// 'func(cmdy.Context) T', where T is any type. To provide a value as an
+// interface, return the interface type from the constructor.
+//
+// The Context passed to the constructor is the Context of the Command that
+// first resolved T.
+//
+// Provide panics if constructor does not have a valid signature, or if T has
+// already been provided.
+func (s *Services) Provide(constructor interface{}) {
+	fn := reflect.ValueOf(constructor)
+	ft := fn.Type()
+	if ft.Kind() != reflect.Func || ft.NumIn() != 1 || ft.In(0) != contextType ||
+		ft.NumOut() < 1 || ft.NumOut() > 2 || (ft.NumOut() == 2 && ft.Out(1) != errorType) {
+		panic(fmt.Errorf("cmdy: service constructor must be 'func(cmdy.Context) (T, error)' or 'func(cmdy.Context) T', found %s", ft))
+	}
+
+	s.add(ft.Out(0), &serviceProvider{
+		close: true,
+		construct: func(ctx Context) (reflect.Value, error) {
+			out := fn.Call([]reflect.Value{reflect.ValueOf(ctx)})
+			if len(out) == 2 && !out[1].IsNil() {
+				return reflect.Value{}, out[1].Interface().(error)
+			}
+			return out[0], nil
+		},
+	})
+}
+
+// ProvideValue registers an existing value under its dynamic type. The value
+// is not closed by the Runner.
+//
+// ProvideValue panics if v is nil, or if its type has already been provided.
+func (s *Services) ProvideValue(v interface{}) {
+	if v == nil {
+		panic(fmt.Errorf("cmdy: nil service value"))
+	}
+	rv := reflect.ValueOf(v)
+	s.add(rv.Type(), &serviceProvider{
+		construct: func(ctx Context) (reflect.Value, error) { return rv, nil },
+	})
+}
+
+func (s *Services) add(t reflect.Type, p *serviceProvider) {
+	if s.providers == nil {
+		s.providers = make(map[reflect.Type]*serviceProvider)
+	}
+	if _, ok := s.providers[t]; ok {
+		panic(fmt.Errorf("cmdy: service %s already provided", t))
+	}
+	s.providers[t] = p
+}
+
+func (s *Services) lookup(t reflect.Type) *serviceProvider {
+	if s == nil {
+		return nil
+	}
+	return s.providers[t]
+}
+
+// ErrServiceNotFound is returned (wrapped) by Resolve if no Services in scope
+// provide the requested type.
+var ErrServiceNotFound = errors.New("cmdy: service not found")
+
+// ErrServiceCycle is returned (wrapped) by Resolve if a constructor resolves,
+// directly or indirectly, the type it constructs.
+var ErrServiceCycle = errors.New("cmdy: cyclic service dependency")
+
+// resolvingContext is passed to service constructors so that Resolve can
+// detect dependency cycles. chain contains the types being constructed,
+// outermost first.
+type resolvingContext struct {
+	Context
+	chain []reflect.Type
+}
+
+// unwrapResolving returns the Context that was passed to the outermost call
+// to Resolve, and the types currently being constructed.
+func unwrapResolving(ctx Context) (Context, []reflect.Type) {
+	if rc, ok := ctx.(*resolvingContext); ok {
+		return rc.Context, rc.chain
+	}
+	return ctx, nil
+}
+
+// Resolve sets the value pointed to by ptr to the service provided for its
+// type, constructing it if necessary. ptr must be a non-nil pointer, and ctx
+// must be a Context created by Runner.Run.
+//
+// Services registered using Scope by the Commands on ctx's Stack are searched
+// first, starting with the innermost, then the Runner's Services.
+func Resolve(ctx Context, ptr interface{}) error {
+	rv := reflect.ValueOf(ptr)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		panic(fmt.Errorf("cmdy: Resolve requires a non-nil pointer, found %T", ptr))
+	}
+	ctx, chain := unwrapResolving(ctx)
+	cctx, ok := ctx.(*commandContext)
+	if !ok {
+		return fmt.Errorf("cmdy: Resolve requires a Context created by Runner.Run")
+	}
+
+	t := rv.Type().Elem()
+	for sc := cctx.currentScope(); sc != nil; sc = sc.parent {
+		if p := sc.services.lookup(t); p != nil {
+			v, err := sc.resolve(ctx, t, p, chain)
+			if err != nil {
+				return err
+			}
+			rv.Elem().Set(v)
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrServiceNotFound, t)
+}
+
+// Scope returns Services that are only visible to the current Command and the
+// subcommands it runs. Values constructed from it are closed when the current
+// Command's Run method returns. This is intended to be used from a Group's
+// Before hook:
+//
+//	cmdy.GroupBefore(func(ctx cmdy.Context) error {
+//		cmdy.Scope(ctx).ProvideValue(&Tenant{Name: tenant})
+//		return nil
+//	})
+//
+// Scope panics if ctx was not created by Runner.Run.
+func Scope(ctx Context) *Services {
+	ctx, _ = unwrapResolving(ctx)
+	cctx, ok := ctx.(*commandContext)
+	if !ok || len(cctx.parents) == 0 {
+		panic(fmt.Errorf("cmdy: Scope requires a Context created by Runner.Run"))
+	}
+	top := &cctx.parents[len(cctx.parents)-1]
+	if top.scope == nil {
+		top.scope = &serviceScope{services: &Services{}, parent: cctx.currentScope()}
+	}
+	return top.scope.services
+}
+
+// serviceScope holds the values constructed from a Services for the lifetime
+// of a call to Runner.Run.
+type serviceScope struct {
+	services *Services
+	parent   *serviceScope
+
+	mu        sync.Mutex
+	instances map[reflect.Type]*serviceInstance
+	closers   []io.Closer
+}
+
+type serviceInstance struct {
+	once  sync.Once
+	value reflect.Value
+	err   error
+}
+
+func (sc *serviceScope) resolve(ctx Context, t reflect.Type, p *serviceProvider, chain []reflect.Type) (reflect.Value, error) {
+	// Without this check, a cycle would deadlock in inst.once.Do:
+	for i, dep := range chain {
+		if dep == t {
+			names := make([]string, 0, len(chain)-i+1)
+			for _, dep := range chain[i:] {
+				names = append(names, dep.String())
+			}
+			names = append(names, t.String())
+			return reflect.Value{}, fmt.Errorf("%w: %s", ErrServiceCycle, strings.Join(names, " -> "))
+		}
+	}
+	chain = append(chain[:len(chain):len(chain)], t)
+
+	sc.mu.Lock()
+	if sc.instances == nil {
+		sc.instances = make(map[reflect.Type]*serviceInstance)
+	}
+	inst := sc.instances[t]
+	if inst == nil {
+		inst = &serviceInstance{}
+		sc.instances[t] = inst
+	}
+	sc.mu.Unlock()
+
+	// The lock is not held while constructing, so constructors can resolve
+	// other services:
+	inst.once.Do(func() {
+		inst.value, inst.err = p.construct(&resolvingContext{Context: ctx, chain: chain})
+		if inst.err != nil {
+			inst.err = fmt.Errorf("cmdy: construct service %s: %w", t, inst.err)
+			return
+		}
+		if closer, ok := inst.value.Interface().(io.Closer); ok && p.close {
+			sc.mu.Lock()
+			sc.closers = append(sc.closers, closer)
+			sc.mu.Unlock()
+		}
+	})
+	return inst.value, inst.err
+}
+
+// close closes the values constructed in the scope in reverse order. If rerr
+// points to a nil error, it is replaced by the first error returned by Close.
+func (sc *serviceScope) close(rerr *error) {
+	sc.mu.Lock()
+	closers := sc.closers
+	sc.closers = nil
+	sc.mu.Unlock()
+
+	for i := len(closers) - 1; i >= 0; i-- {
+		if err := closers[i].Close(); err != nil && *rerr == nil {
+			*rerr = err
+		}
+	}
+}
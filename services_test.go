@@ -0,0 +1,217 @@
+package cmdy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/shabbyrobe/cmdy/internal/assert"
+)
+
+type testService struct {
+	name   string
+	log    *[]string
+	closed bool
+}
+
+func (s *testService) Close() error {
+	s.closed = true
+	*s.log = append(*s.log, "close "+s.name)
+	return nil
+}
+
+type testTenant string
+
+func TestServicesResolveLazilyAndClose(t *testing.T) {
+	tt := assert.WrapTB(t)
+
+	var log []string
+	services := &Services{}
+	services.Provide(func(ctx Context) (*testService, error) {
+		log = append(log, "construct")
+		return &testService{name: "svc", log: &log}, nil
+	})
+
+	var resolved *testService
+	bld := testCmdRunBuilder(func(ctx Context) error {
+		log = append(log, "run")
+		var a, b *testService
+		tt.MustOK(Resolve(ctx, &a))
+		tt.MustOK(Resolve(ctx, &b))
+		tt.MustAssert(a == b)
+		resolved = a
+		return nil
+	})
+
+	rn := NewBufferedRunner()
+	rn.Services = services
+	tt.MustOK(rn.Run(context.Background(), "test", nil, bld))
+	tt.MustEqual([]string{"run", "construct", "close svc"}, log)
+	tt.MustAssert(resolved.closed)
+
+	// Each Run gets a new instance:
+	log = nil
+	tt.MustOK(rn.Run(context.Background(), "test", nil, bld))
+	tt.MustEqual([]string{"run", "construct", "close svc"}, log)
+}
+
+func TestServicesProvideValueNotClosed(t *testing.T) {
+	tt := assert.WrapTB(t)
+
+	var log []string
+	svc := &testService{name: "svc", log: &log}
+	services := &Services{}
+	services.ProvideValue(svc)
+
+	rn := NewBufferedRunner()
+	rn.Services = services
+	tt.MustOK(rn.Run(context.Background(), "test", nil, testCmdRunBuilder(func(ctx Context) error {
+		var out *testService
+		tt.MustOK(Resolve(ctx, &out))
+		tt.MustAssert(out == svc)
+		return nil
+	})))
+	tt.MustAssert(!svc.closed)
+}
+
+func TestServicesNotFound(t *testing.T) {
+	tt := assert.WrapTB(t)
+
+	rn := NewBufferedRunner()
+	err := rn.Run(context.Background(), "test", nil, testCmdRunBuilder(func(ctx Context) error {
+		var out *testService
+		return Resolve(ctx, &out)
+	}))
+	tt.MustAssert(errors.Is(err, ErrServiceNotFound))
+}
+
+func TestServicesConstructorError(t *testing.T) {
+	tt := assert.WrapTB(t)
+
+	services := &Services{}
+	services.Provide(func(ctx Context) (*testService, error) {
+		return nil, fmt.Errorf("nope")
+	})
+
+	rn := NewBufferedRunner()
+	rn.Services = services
+	err := rn.Run(context.Background(), "test", nil, testCmdRunBuilder(func(ctx Context) error {
+		var out *testService
+		return Resolve(ctx, &out)
+	}))
+	tt.MustEqual("cmdy: construct service *cmdy.testService: nope", err.Error())
+}
+
+type testServiceDep struct{}
+
+func TestServicesCycle(t *testing.T) {
+	tt := assert.WrapTB(t)
+
+	services := &Services{}
+	services.Provide(func(ctx Context) (*testService, error) {
+		var dep *testServiceDep
+		return nil, Resolve(ctx, &dep)
+	})
+	services.Provide(func(ctx Context) (*testServiceDep, error) {
+		var svc *testService
+		return nil, Resolve(ctx, &svc)
+	})
+
+	rn := NewBufferedRunner()
+	rn.Services = services
+	err := rn.Run(context.Background(), "test", nil, testCmdRunBuilder(func(ctx Context) error {
+		var out *testService
+		return Resolve(ctx, &out)
+	}))
+	tt.MustAssert(errors.Is(err, ErrServiceCycle), err)
+	tt.MustAssert(strings.Contains(err.Error(),
+		"cyclic service dependency: *cmdy.testService -> *cmdy.testServiceDep -> *cmdy.testService"), err)
+}
+
+func TestServicesSelfCycle(t *testing.T) {
+	tt := assert.WrapTB(t)
+
+	services := &Services{}
+	services.Provide(func(ctx Context) (*testService, error) {
+		var self *testService
+		return self, Resolve(ctx, &self)
+	})
+
+	rn := NewBufferedRunner()
+	rn.Services = services
+	err := rn.Run(context.Background(), "test", nil, testCmdRunBuilder(func(ctx Context) error {
+		var out *testService
+		return Resolve(ctx, &out)
+	}))
+	tt.MustAssert(errors.Is(err, ErrServiceCycle), err)
+}
+
+func TestServicesScope(t *testing.T) {
+	tt := assert.WrapTB(t)
+
+	var log []string
+	var tenants []testTenant
+	bld := func() Command {
+		return NewGroup("grp", Builders{
+			"sub": testCmdRunBuilder(func(ctx Context) error {
+				var tenant testTenant
+				tt.MustOK(Resolve(ctx, &tenant))
+				tenants = append(tenants, tenant)
+
+				var svc *testService
+				tt.MustOK(Resolve(ctx, &svc))
+				log = append(log, "run")
+				return nil
+			}),
+		}, GroupBefore(func(ctx Context) error {
+			scope := Scope(ctx)
+			scope.ProvideValue(testTenant("yep"))
+			scope.Provide(func(ctx Context) *testService {
+				return &testService{name: "scoped", log: &log}
+			})
+			return nil
+		}), GroupAfter(func(ctx Context, err error) error {
+			log = append(log, "after")
+			return err
+		}))
+	}
+
+	rn := NewBufferedRunner()
+	tt.MustOK(rn.Run(context.Background(), "test", []string{"sub"}, bld))
+	tt.MustEqual([]testTenant{"yep"}, tenants)
+
+	// Scoped values are closed when the Group's Run returns, after the After
+	// hook has been called:
+	tt.MustEqual([]string{"run", "after", "close scoped"}, log)
+}
+
+func TestServicesProvidePanics(t *testing.T) {
+	for idx, fn := range []interface{}{
+		1,
+		func() *testService { return nil },
+		func(ctx Context) {},
+		func(ctx Context) (*testService, string) { return nil, "" },
+	} {
+		t.Run(fmt.Sprint(idx), func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("expected panic")
+				}
+			}()
+			(&Services{}).Provide(fn)
+		})
+	}
+
+	t.Run("duplicate", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("expected panic")
+			}
+		}()
+		var s Services
+		s.ProvideValue(testTenant("a"))
+		s.ProvideValue(testTenant("b"))
+	})
+}
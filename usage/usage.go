@@ -51,7 +51,7 @@ func Usage(width int, usables ...Usable) string {
 
 		// Boolean flags of one ASCII letter are so common we
 		// treat them specially, putting their usage on the same line.
-		if len(s) <= 4 { // space, space, '-', 'x'.
+		if wrap.StringWidth(s) <= 4 { // space, space, '-', 'x'.
 			s += indentFlag
 		} else if usage != "" || showDefault {
 			s += "\n" + indent